@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/apikeys"
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/web"
+)
+
+type apiKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// apiKeyResponse nunca incluye el hash; Key solo viaja en la respuesta de la emisión.
+type apiKeyResponse struct {
+	Id        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	Key       string     `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(k domain.APIKey) apiKeyResponse {
+	return apiKeyResponse{Id: k.Id, Name: k.Name, Prefix: k.Prefix, Scopes: k.Scopes, CreatedAt: k.CreatedAt, ExpiresAt: k.ExpiresAt, RevokedAt: k.RevokedAt}
+}
+
+type APIKey struct {
+	service apikeys.Service
+}
+
+func NewAPIKey(s apikeys.Service) *APIKey {
+	return &APIKey{service: s}
+}
+
+// ListAPIKeys godoc
+// @Summary Lists API keys
+// @Tags API keys
+// @Description lists issued API keys, including revoked and expired ones
+// @Produce json
+// @Param token header string true "token with keys:admin scope"
+// @Success 200 {object} web.Response
+// @Failure 401 {object} web.Response
+// @Failure 403 {object} web.Response
+// @Router /apikeys [get]
+func (c *APIKey) List() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		keys, err := c.service.List(ctx.Request.Context())
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		response := make([]apiKeyResponse, 0, len(keys))
+		for _, k := range keys {
+			response = append(response, newAPIKeyResponse(k))
+		}
+		ctx.JSON(200, web.NewResponse(200, response, ""))
+	}
+}
+
+// IssueAPIKeys godoc
+// @Summary Issues an API key
+// @Tags API keys
+// @Description issues a new API key; the key value is only returned in this response
+// @Accept json
+// @Produce json
+// @Param token header string true "token with keys:admin scope"
+// @Param apiKey body apiKeyRequest true "API key to issue"
+// @Success 201 {object} web.Response
+// @Failure 400 {object} web.Response
+// @Failure 401 {object} web.Response
+// @Failure 403 {object} web.Response
+// @Router /apikeys [post]
+func (c *APIKey) Issue() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req apiKeyRequest
+		if err := bindJSON(ctx, &req); err != nil {
+			ctx.Error(apperrors.Validation("invalid_body", err.Error()))
+			return
+		}
+
+		plain, key, err := c.service.Issue(ctx.Request.Context(), req.Name, req.Scopes, req.ExpiresAt)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		response := newAPIKeyResponse(key)
+		response.Key = plain
+		ctx.JSON(201, web.NewResponse(201, response, ""))
+	}
+}
+
+// RevokeAPIKeys godoc
+// @Summary Revokes an API key
+// @Tags API keys
+// @Description revokes an API key; it stops being accepted immediately
+// @Produce json
+// @Param token header string true "token with keys:admin scope"
+// @Param id path string true "API key id"
+// @Success 200 {object} web.Response
+// @Failure 401 {object} web.Response
+// @Failure 403 {object} web.Response
+// @Failure 404 {object} web.Response
+// @Router /apikeys/{id} [delete]
+func (c *APIKey) Revoke() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key, err := c.service.Revoke(ctx.Request.Context(), ctx.Param("id"))
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		ctx.JSON(200, web.NewResponse(200, newAPIKeyResponse(key), ""))
+	}
+}
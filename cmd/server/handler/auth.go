@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/apikeys"
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/audit"
+	"github.com/palomavs/go-web-II/pkg/jwtauth"
+	"github.com/palomavs/go-web-II/pkg/logging"
+)
+
+// Claves del gin.Context donde queda la identidad de quien hace la petición.
+const (
+	// APIKeyContextKey guarda la domain.APIKey cuando la petición se autenticó con una API key.
+	APIKeyContextKey = "apiKey"
+	// SubjectContextKey guarda el sub del JWT o el id de la API key.
+	SubjectContextKey = "subject"
+	// RolesContextKey guarda los roles del JWT; las API keys no tienen roles.
+	RolesContextKey = "roles"
+	// ScopesContextKey guarda los scopes de la credencial.
+	ScopesContextKey = "scopes"
+)
+
+type principal struct {
+	subject string
+	// method es cómo se autenticó: jwt, apikey o legacy.
+	method string
+	roles  []string
+	scopes []string
+	key    *domain.APIKey
+}
+
+// logFields identifica a la credencial en los logs de la petición, sin incluir ningún secreto.
+func (p principal) logFields() map[string]interface{} {
+	fields := map[string]interface{}{"subject": p.subject, "authMethod": p.method}
+	if len(p.roles) > 0 {
+		fields["roles"] = p.roles
+	}
+	if p.key != nil {
+		fields["apiKeyPrefix"] = p.key.Prefix
+	}
+	return fields
+}
+
+func (p principal) hasScope(scope string) bool {
+	for _, s := range p.scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type Auth struct {
+	service     apikeys.Service
+	legacyToken string
+	verifier    *jwtauth.Verifier
+	audit       audit.Logger
+}
+
+// NewAuth crea el autenticador. Si legacyToken no es vacío, ese valor se acepta como una clave
+// con todos los scopes, para poder emitir las primeras claves y migrar a los clientes que
+// todavía usan el TOKEN único. Si verifier es nil no se aceptan tokens Bearer. Cada petición
+// rechazada queda registrada en auditLog.
+func NewAuth(s apikeys.Service, legacyToken string, verifier *jwtauth.Verifier, auditLog audit.Logger) *Auth {
+	return &Auth{service: s, legacyToken: legacyToken, verifier: verifier, audit: auditLog}
+}
+
+// Authorize exige que la petición traiga una credencial válida, un JWT en el header
+// Authorization: Bearer o una API key en el header token, que cumpla la regla de la ruta en policy.
+func (a *Auth) Authorize(policy Policy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		p, err := a.authenticate(ctx)
+		var denied *apperrors.Error
+		if errors.As(err, &denied) && denied.Kind == apperrors.KindUnauthorized {
+			a.deny(ctx, p, "", denied)
+			return
+		}
+		if err != nil {
+			ctx.Error(err)
+			ctx.Abort()
+			return
+		}
+
+		rule, ok := policy[ctx.Request.Method+" "+ctx.FullPath()]
+		if !ok {
+			logging.FromContext(ctx.Request.Context()).Error().Str("route", ctx.Request.Method+" "+ctx.FullPath()).Msg("la ruta no tiene una regla de autorización")
+			a.deny(ctx, p, "", apperrors.Forbidden("forbidden"))
+			return
+		}
+		if !rule.allows(p) {
+			a.deny(ctx, p, rule.Permission, apperrors.Forbidden("forbidden_scope", rule.Permission))
+			return
+		}
+
+		logging.AddFields(ctx.Request.Context(), p.logFields())
+		ctx.Set(SubjectContextKey, p.subject)
+		ctx.Set(RolesContextKey, p.roles)
+		ctx.Set(ScopesContextKey, p.scopes)
+		if p.key != nil {
+			ctx.Set(APIKeyContextKey, *p.key)
+		}
+	}
+}
+
+func (a *Auth) deny(ctx *gin.Context, p principal, permission string, err *apperrors.Error) {
+	if p.subject != "" {
+		logging.AddFields(ctx.Request.Context(), p.logFields())
+	}
+	a.audit.Record(audit.Entry{
+		Action:     audit.ActionAccessDenied,
+		Subject:    p.subject,
+		Roles:      p.roles,
+		Method:     ctx.Request.Method,
+		Path:       ctx.Request.URL.Path,
+		Permission: permission,
+		Reason:     err.Code,
+		RemoteAddr: ctx.ClientIP(),
+	})
+	ctx.Error(err)
+	ctx.Abort()
+}
+
+func (a *Auth) authenticate(ctx *gin.Context) (principal, error) {
+	if header := ctx.GetHeader("Authorization"); header != "" {
+		return a.bearer(header)
+	}
+
+	token := ctx.GetHeader("token")
+	if a.legacyToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.legacyToken)) == 1 {
+		return principal{
+			subject: "legacy",
+			method:  "legacy",
+			scopes:  []string{domain.ScopeProductsRead, domain.ScopeProductsReadInactive, domain.ScopeProductsWrite, domain.ScopeProductsPurge, domain.ScopeKeysAdmin},
+		}, nil
+	}
+
+	key, err := a.service.Authenticate(ctx.Request.Context(), token)
+	if err != nil {
+		return principal{}, err
+	}
+	return principal{subject: key.Id, method: "apikey", scopes: key.Scopes, key: &key}, nil
+}
+
+func (a *Auth) bearer(header string) (principal, error) {
+	const scheme = "bearer "
+	if a.verifier == nil || len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return principal{}, apperrors.Unauthorized("unauthorized")
+	}
+
+	claims, err := a.verifier.Verify(strings.TrimSpace(header[len(scheme):]))
+	if err != nil {
+		return principal{}, err
+	}
+	return principal{subject: claims.Subject, method: "jwt", roles: claims.Roles, scopes: claims.Scopes()}, nil
+}
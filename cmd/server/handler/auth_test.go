@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/palomavs/go-web-II/internal/apikeys"
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/audit"
+	"github.com/palomavs/go-web-II/pkg/jwtauth"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/palomavs/go-web-II/pkg/web"
+	"github.com/stretchr/testify/assert"
+)
+
+var jwtSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func bearerToken(t *testing.T, audience, scope string, roles ...string) string {
+	claims := jwtauth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "ana",
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: roles,
+		Scope: scope,
+	}
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	assert.Nil(t, err, "no debería dar error")
+	return "Bearer " + raw
+}
+
+// startAuthServer registra las rutas de productos con la política real y handlers que solo
+// responden 200, para probar la autorización sin depender del servicio.
+func startAuthServer(t *testing.T) (*gin.Engine, apikeys.Service, *bytes.Buffer) {
+	service := apikeys.NewService(apikeys.NewRepository(store.New(store.FileType, filepath.Join(t.TempDir(), "apikeys.json"))))
+	verifier := jwtauth.NewVerifier([]jwtauth.Key{{Public: jwtSecret}}, "products-api", "")
+	auditLog := &bytes.Buffer{}
+	auth := NewAuth(service, "legacy-token", verifier, audit.NewLogger(auditLog))
+
+	r := gin.Default()
+	r.Use(web.ErrorHandler())
+	ok := func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"subject": ctx.GetString(SubjectContextKey), "roles": ctx.GetStringSlice(RolesContextKey)})
+	}
+	pr := r.Group("/products", auth.Authorize(ProductPolicy))
+	{
+		pr.GET("/", ok)
+		pr.GET("/:id", ok)
+		pr.PUT("/:id", ok)
+		pr.DELETE("/hardDelete/:id", ok)
+		pr.GET("/sinRegla", ok)
+	}
+	return r, service, auditLog
+}
+
+func auditEntries(t *testing.T, buf *bytes.Buffer) []audit.Entry {
+	var entries []audit.Entry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e audit.Entry
+		assert.Nil(t, json.Unmarshal([]byte(line), &e), "no debería dar error")
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAuthorize_APIKeyScopes(t *testing.T) {
+	r, service, auditLog := startAuthServer(t)
+	plain, _, err := service.Issue(context.Background(), "catalogo", []string{domain.ScopeProductsRead}, nil)
+	assert.Nil(t, err, "no debería dar error")
+
+	cases := []struct {
+		method, url, token string
+		status             int
+	}{
+		{http.MethodGet, "/products/", "", http.StatusUnauthorized},
+		{http.MethodGet, "/products/", "gw_inexistente", http.StatusUnauthorized},
+		{http.MethodGet, "/products/1", plain, http.StatusOK},
+		{http.MethodDelete, "/products/hardDelete/1", plain, http.StatusForbidden},
+		{http.MethodDelete, "/products/hardDelete/1", "legacy-token", http.StatusOK},
+		{http.MethodGet, "/products/sinRegla", "legacy-token", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req, res := createRequestTest(c.method, c.url, nil)
+		req.Header.Set("token", c.token)
+		r.ServeHTTP(res, req)
+		assert.Equal(t, c.status, res.Code, "%s %s con token %q", c.method, c.url, c.token)
+	}
+
+	assert.Len(t, auditEntries(t, auditLog), 4, "cada rechazo debe quedar auditado")
+}
+
+func TestAuthorize_Roles(t *testing.T) {
+	r, _, auditLog := startAuthServer(t)
+
+	cases := []struct {
+		method, url, role string
+		status            int
+	}{
+		{http.MethodGet, "/products/", domain.RoleViewer, http.StatusOK},
+		{http.MethodPut, "/products/1", domain.RoleViewer, http.StatusForbidden},
+		{http.MethodPut, "/products/1", domain.RoleEditor, http.StatusOK},
+		{http.MethodDelete, "/products/hardDelete/1", domain.RoleEditor, http.StatusForbidden},
+		{http.MethodDelete, "/products/hardDelete/1", domain.RoleAdmin, http.StatusOK},
+	}
+	for _, c := range cases {
+		req, res := createRequestTest(c.method, c.url, nil)
+		req.Header.Set("Authorization", bearerToken(t, "products-api", "", c.role))
+		r.ServeHTTP(res, req)
+		assert.Equal(t, c.status, res.Code, "%s %s con rol %s", c.method, c.url, c.role)
+	}
+
+	entries := auditEntries(t, auditLog)
+	assert.Len(t, entries, 2, "cada rechazo debe quedar auditado")
+	assert.Equal(t, audit.ActionAccessDenied, entries[1].Action, "deben ser iguales")
+	assert.Equal(t, "ana", entries[1].Subject, "deben ser iguales")
+	assert.Equal(t, []string{domain.RoleEditor}, entries[1].Roles, "deben ser iguales")
+	assert.Equal(t, "/products/hardDelete/1", entries[1].Path, "deben ser iguales")
+	assert.Equal(t, domain.ScopeProductsPurge, entries[1].Permission, "deben ser iguales")
+	assert.Equal(t, "forbidden_scope", entries[1].Reason, "deben ser iguales")
+}
+
+func TestAuthorize_ForbiddenNamesPermission(t *testing.T) {
+	r, _, _ := startAuthServer(t)
+
+	req, res := createRequestTest(http.MethodPut, "/products/1", nil)
+	req.Header.Set("Authorization", bearerToken(t, "products-api", "", domain.RoleViewer))
+	r.ServeHTTP(res, req)
+
+	var body web.Response
+	assert.Nil(t, json.Unmarshal(res.Body.Bytes(), &body), "no debería dar error")
+	assert.Equal(t, "403", body.Code, "deben ser iguales")
+	assert.Contains(t, body.Error, domain.ScopeProductsWrite, "el error debe nombrar el permiso faltante")
+}
+
+func TestAuthorize_Bearer(t *testing.T) {
+	r, _, _ := startAuthServer(t)
+
+	req, res := createRequestTest(http.MethodGet, "/products/", nil)
+	req.Header.Set("Authorization", bearerToken(t, "products-api", "products:read", domain.RoleViewer))
+	r.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code, "deben ser iguales")
+	assert.JSONEq(t, `{"subject":"ana","roles":["viewer"]}`, res.Body.String(), "deben ser iguales")
+
+	cases := []struct {
+		method, url, authorization string
+		status                     int
+	}{
+		{http.MethodGet, "/products/", bearerToken(t, "billing-api", "products:read"), http.StatusUnauthorized},
+		{http.MethodGet, "/products/", "Basic YW5hOmFuYQ==", http.StatusUnauthorized},
+		{http.MethodPut, "/products/1", bearerToken(t, "products-api", "products:write"), http.StatusOK},
+		{http.MethodDelete, "/products/hardDelete/1", bearerToken(t, "products-api", "products:read"), http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req, res := createRequestTest(c.method, c.url, nil)
+		req.Header.Set("Authorization", c.authorization)
+		r.ServeHTTP(res, req)
+		assert.Equal(t, c.status, res.Code, "%s %s", c.method, c.url)
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/internal/products"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/i18n"
+	"github.com/palomavs/go-web-II/pkg/web"
+)
+
+// maxBulkOperations limita el tamaño de un lote, que se resuelve completo en memoria.
+const maxBulkOperations = 10000
+
+type bulkRequest struct {
+	// Atomic descarta todo el lote si falla alguna operación.
+	Atomic bool `json:"atomic"`
+	// ContinueOnError sigue con el resto del lote después de una falla.
+	ContinueOnError bool            `json:"continueOnError"`
+	Operations      []bulkOperation `json:"operations"`
+}
+
+type bulkOperation struct {
+	// Op es create, update o delete.
+	Op string `json:"op"`
+	// Id identifica el producto de update y delete.
+	Id int `json:"id"`
+	// Version es la versión esperada del producto; si se omite no se controla.
+	Version *int    `json:"version"`
+	Product request `json:"product"`
+}
+
+type bulkResult struct {
+	Index  int                    `json:"index"`
+	Op     string                 `json:"op"`
+	Status int                    `json:"status"`
+	Data   *domain.Product        `json:"data,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+	Errors []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// BulkProducts godoc
+// @Summary Creates, updates and deletes products in a single batch
+// @Tags Products
+// @Description applies the operations in order and stores them together. Each result has the status the single-item endpoint would answer, or 424 if the operation was not applied because another one failed. Without atomic, processing stops at the first failure unless continueOnError is set.
+// @Accept json
+// @Produce json
+// @Param token header string true "token"
+// @Param batch body bulkRequest true "Operations to apply"
+// @Success 200 {object} web.Response "every operation was applied"
+// @Success 207 {object} web.Response "some operations failed"
+// @Failure 400 {object} web.Response
+// @Failure 409 {object} web.Response "atomic batch discarded, with the status of its first failure"
+// @Router /products/bulk [post]
+func (c *Product) Bulk() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req bulkRequest
+		if err := bindJSON(ctx, &req); err != nil {
+			ctx.Error(apperrors.Validation("invalid_body", err.Error()))
+			return
+		}
+		if len(req.Operations) == 0 {
+			ctx.Error(apperrors.Validation("invalid_body", "operations"))
+			return
+		}
+		if len(req.Operations) > maxBulkOperations {
+			ctx.Error(apperrors.Validation("too_many_operations", maxBulkOperations))
+			return
+		}
+
+		ops := make([]products.BulkOperation, len(req.Operations))
+		for i, op := range req.Operations {
+			p := op.Product
+			ops[i] = products.BulkOperation{
+				Op:      op.Op,
+				Product: domain.Product{Id: op.Id, Name: p.Name, Color: p.Color, Price: p.Price, Stock: p.Stock, Code: p.Code, Published: p.Published, CreationDate: p.CreationDate, Active: p.Active},
+				Version: products.AnyVersion,
+			}
+			if op.Version != nil {
+				ops[i].Version = *op.Version
+			}
+		}
+
+		results, err := c.service.Bulk(ctx.Request.Context(), ops, ctx.GetString(SubjectContextKey), products.BulkOptions{Atomic: req.Atomic, ContinueOnError: req.ContinueOnError})
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		locale := i18n.Default.Negotiate(ctx.GetHeader("Accept-Language"))
+		response := make([]bulkResult, len(results))
+		var firstErr error
+		for i, result := range results {
+			response[i] = bulkResult{Index: i, Op: req.Operations[i].Op, Status: http.StatusOK}
+			switch {
+			case result.Err != nil:
+				response[i].Status = web.StatusOf(result.Err)
+				response[i].Error, response[i].Errors = apperrors.Localize(result.Err, locale)
+				if firstErr == nil {
+					firstErr = result.Err
+				}
+			case !result.Applied:
+				response[i].Status = http.StatusFailedDependency
+				response[i].Error = i18n.Default.Message(locale, "operation_not_applied")
+			default:
+				product := result.Product
+				response[i].Data = &product
+			}
+		}
+
+		if firstErr == nil {
+			ctx.JSON(http.StatusOK, web.NewResponse(http.StatusOK, response, ""))
+			return
+		}
+		ctx.Header("Content-Language", locale)
+		if req.Atomic {
+			// El lote no se guardó: se responde como la primera falla, con el detalle de cada operación
+			status := web.StatusOf(firstErr)
+			message, _ := apperrors.Localize(firstErr, locale)
+			ctx.JSON(status, web.Response{Code: strconv.Itoa(status), Data: response, Error: message})
+		} else {
+			ctx.JSON(http.StatusMultiStatus, web.NewResponse(http.StatusMultiStatus, response, ""))
+		}
+	}
+}
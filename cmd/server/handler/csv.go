@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/internal/products"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/i18n"
+	"github.com/palomavs/go-web-II/pkg/web"
+)
+
+// maxImportBytes limita el tamaño del archivo que acepta POST /products/import.
+const maxImportBytes = 10 << 20
+
+type importResponse struct {
+	DryRun         bool        `json:"dryRun"`
+	Applied        bool        `json:"applied"`
+	Created        int         `json:"created"`
+	Updated        int         `json:"updated"`
+	Unchanged      int         `json:"unchanged"`
+	Failed         int         `json:"failed"`
+	IgnoredColumns []string    `json:"ignoredColumns,omitempty"`
+	Rows           []importRow `json:"rows"`
+}
+
+type importRow struct {
+	Line   int                    `json:"line"`
+	Action string                 `json:"action,omitempty"`
+	Data   *domain.Product        `json:"data,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+	Errors []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// ExportProducts godoc
+// @Summary Exports products
+// @Tags Products
+// @Description downloads the catalog as CSV, with the columns that import accepts
+// @Produce text/csv
+// @Param token header string true "token"
+// @Param format query string false "export format, only csv is supported" default(csv)
+// @Param includeInactive query boolean false "include soft-deleted products; requires the admin role or the products:read-inactive scope"
+// @Success 200 {file} file
+// @Failure 400 {object} web.Response
+// @Failure 403 {object} web.Response
+// @Router /products/export [get]
+func (c *Product) Export() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if format := ctx.DefaultQuery("format", "csv"); format != "csv" {
+			ctx.Error(apperrors.Validation("invalid_param", "format"))
+			return
+		}
+		includeInactive, err := queryIncludeInactive(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		all, err := c.service.GetAll(ctx.Request.Context())
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		exported := []domain.Product{}
+		for _, p := range all {
+			if p.Active || includeInactive {
+				exported = append(exported, p)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := products.WriteCSV(&buf, exported); err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.Header("Content-Disposition", `attachment; filename="products.csv"`)
+		ctx.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	}
+}
+
+// ImportProducts godoc
+// @Summary Imports products
+// @Tags Products
+// @Description creates or updates products from a CSV, matching them by code. Headers are matched to product fields ignoring case, spaces and dashes, or through mapping. Only the columns in the file are updated. Rows are all saved or none is; the status of a failed import is the one of its first failed row.
+// @Accept multipart/form-data
+// @Produce json
+// @Param token header string true "token"
+// @Param file formData file true "CSV file"
+// @Param mapping formData string false "JSON object from CSV header to product field, e.g. {\"SKU\": \"code\"}"
+// @Param dryRun query boolean false "only report what would change"
+// @Success 200 {object} web.Response
+// @Failure 400 {object} web.Response
+// @Failure 409 {object} web.Response
+// @Router /products/import [post]
+func (c *Product) Import() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dryRun", "false"))
+		if err != nil {
+			ctx.Error(apperrors.Validation("invalid_param", "dryRun"))
+			return
+		}
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportBytes)
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			ctx.Error(apperrors.Validation("invalid_body", err.Error()))
+			return
+		}
+		var mapping map[string]string
+		if raw := ctx.PostForm("mapping"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+				ctx.Error(apperrors.Validation("invalid_param", "mapping"))
+				return
+			}
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			ctx.Error(apperrors.Validation("invalid_body", err.Error()))
+			return
+		}
+		defer file.Close()
+		csvFile, err := products.ReadCSV(file, mapping)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		report, err := c.service.Import(ctx.Request.Context(), csvFile, ctx.GetString(SubjectContextKey), dryRun)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		locale := i18n.Default.Negotiate(ctx.GetHeader("Accept-Language"))
+		response := importResponse{
+			DryRun:         dryRun,
+			Applied:        report.Applied,
+			Created:        report.Created,
+			Updated:        report.Updated,
+			Unchanged:      report.Unchanged,
+			Failed:         report.Failed,
+			IgnoredColumns: csvFile.Ignored,
+			Rows:           make([]importRow, len(report.Results)),
+		}
+		var firstErr error
+		for i, result := range report.Results {
+			response.Rows[i] = importRow{Line: result.Line, Action: result.Action}
+			if result.Err != nil {
+				response.Rows[i].Error, response.Rows[i].Errors = apperrors.Localize(result.Err, locale)
+				if firstErr == nil {
+					firstErr = result.Err
+				}
+				continue
+			}
+			product := result.Product
+			response.Rows[i].Data = &product
+		}
+
+		// Un dry run siempre responde 200: las fallas son parte de la vista previa
+		if firstErr == nil || dryRun {
+			ctx.JSON(http.StatusOK, web.NewResponse(http.StatusOK, response, ""))
+			return
+		}
+		status := web.StatusOf(firstErr)
+		message, _ := apperrors.Localize(firstErr, locale)
+		ctx.Header("Content-Language", locale)
+		ctx.JSON(status, web.Response{Code: strconv.Itoa(status), Data: response, Error: message})
+	}
+}
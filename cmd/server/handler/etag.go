@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/internal/products"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+// etag es el ETag de un producto: su versión, como validador fuerte.
+func etag(p domain.Product) string {
+	return `"` + strconv.Itoa(p.Version) + `"`
+}
+
+// parseETags separa los ETags de un header If-Match o If-None-Match.
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseVersion devuelve la versión de un ETag fuerte generado por etag.
+func parseVersion(tag string) (int, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	return version, err == nil && version >= 0
+}
+
+// ifMatch devuelve la versión que el cliente espera modificar según If-Match, o
+// products.AnyVersion si no lo envía o envía "*". La comparación es fuerte, así que un ETag débil
+// nunca coincide. Si se listan varios ETags se elige el que coincide con la versión actual; el
+// repositorio vuelve a controlarla al escribir.
+func (c *Product) ifMatch(ctx *gin.Context, id int) (int, error) {
+	header := ctx.GetHeader("If-Match")
+	if header == "" {
+		return products.AnyVersion, nil
+	}
+
+	var versions []int
+	for _, tag := range parseETags(header) {
+		if tag == "*" {
+			return products.AnyVersion, nil
+		}
+		if version, ok := parseVersion(tag); ok {
+			versions = append(versions, version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, apperrors.PreconditionFailed("version_mismatch", id)
+	case 1:
+		return versions[0], nil
+	}
+
+	current, err := c.service.GetByID(ctx.Request.Context(), id, true)
+	if err != nil {
+		return 0, err
+	}
+	for _, version := range versions {
+		if version == current.Version {
+			return version, nil
+		}
+	}
+	return 0, apperrors.PreconditionFailed("version_mismatch", id)
+}
+
+// notModified indica si, según If-None-Match, el cliente ya tiene la versión actual de p. La
+// comparación es débil: W/"3" coincide con "3".
+func notModified(ctx *gin.Context, p domain.Product) bool {
+	current := etag(p)
+	for _, tag := range parseETags(ctx.GetHeader("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
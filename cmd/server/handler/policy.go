@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/domain"
+)
+
+// Rule es el permiso que exige una ruta y los roles que lo tienen. Las credenciales sin roles,
+// como las API keys, deben tener el permiso entre sus scopes.
+type Rule struct {
+	Permission string
+	Roles      []string
+}
+
+// Policy asocia cada ruta, con la forma "MÉTODO /ruta/completa" de gin, a su regla. Las rutas
+// que no están en la tabla se rechazan.
+type Policy map[string]Rule
+
+var (
+	readers = []string{domain.RoleViewer, domain.RoleEditor, domain.RoleAdmin}
+	editors = []string{domain.RoleEditor, domain.RoleAdmin}
+	admins  = []string{domain.RoleAdmin}
+)
+
+// ProductPolicy es la política de las rutas de handler.Product.
+var ProductPolicy = Policy{
+	"GET /products/":                  {domain.ScopeProductsRead, readers},
+	"GET /products/trash":             InactiveRule,
+	"GET /products/export":            {domain.ScopeProductsRead, readers},
+	"GET /products/by-code/:code":     {domain.ScopeProductsRead, readers},
+	"GET /products/:id":               {domain.ScopeProductsRead, readers},
+	"POST /products/":                 {domain.ScopeProductsWrite, editors},
+	"POST /products/bulk":             {domain.ScopeProductsWrite, editors},
+	"POST /products/import":           {domain.ScopeProductsWrite, editors},
+	"PUT /products/:id":               {domain.ScopeProductsWrite, editors},
+	"PATCH /products/:id":             {domain.ScopeProductsWrite, editors},
+	"DELETE /products/:id":            {domain.ScopeProductsWrite, editors},
+	"POST /products/:id/restore":      {domain.ScopeProductsWrite, editors},
+	"DELETE /products/hardDelete/:id": {domain.ScopeProductsPurge, admins},
+}
+
+// InactiveRule es la regla de la papelera y lo que exige, además de la regla de la ruta,
+// consultar productos dados de baja con includeInactive=true o active=false.
+var InactiveRule = Rule{domain.ScopeProductsReadInactive, admins}
+
+// APIKeyPolicy es la política de las rutas de handler.APIKey.
+var APIKeyPolicy = Policy{
+	"GET /apikeys/":       {domain.ScopeKeysAdmin, admins},
+	"POST /apikeys/":      {domain.ScopeKeysAdmin, admins},
+	"DELETE /apikeys/:id": {domain.ScopeKeysAdmin, admins},
+}
+
+// permitted indica si quien hace la petición, ya autorizado por Authorize, cumple además rule.
+func permitted(ctx *gin.Context, rule Rule) bool {
+	return rule.allows(principal{roles: ctx.GetStringSlice(RolesContextKey), scopes: ctx.GetStringSlice(ScopesContextKey)})
+}
+
+func (r Rule) allows(p principal) bool {
+	for _, role := range p.roles {
+		for _, allowed := range r.Roles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return p.hasScope(r.Permission)
+}
@@ -1,12 +1,15 @@
 package handler
 
 import (
-	"context"
-	"os"
+	"io"
+	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/palomavs/go-web-II/internal/products"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/jsonpatch"
+	"github.com/palomavs/go-web-II/pkg/tracing"
 	"github.com/palomavs/go-web-II/pkg/web"
 )
 
@@ -33,22 +36,129 @@ func NewProduct(p products.Service) *Product {
 // ListProducts godoc
 // @Summary Lists products
 // @Tags Products
-// @Description get products
+// @Description get products, filtered, sorted and paginated. Only active products are listed unless active or includeInactive is given.
 // @Accept json
 // @Produce json
 // @Param token header string true "token"
+// @Param name query string false "name contains (case insensitive)"
+// @Param color query string false "comma separated list of colors"
+// @Param priceMin query number false "minimum price"
+// @Param priceMax query number false "maximum price"
+// @Param stockBelow query integer false "stock strictly below"
+// @Param published query boolean false "published"
+// @Param active query boolean false "active; false requires the admin role or the products:read-inactive scope"
+// @Param includeInactive query boolean false "include soft-deleted products; requires the admin role or the products:read-inactive scope"
+// @Param creationDateFrom query string false "creation date from (d-m-yyyy)"
+// @Param creationDateTo query string false "creation date to (d-m-yyyy)"
+// @Param sort query string false "comma separated fields, prefix - for descending (e.g. -price,name)"
+// @Param limit query integer false "page size"
+// @Param offset query integer false "offset pagination"
+// @Param cursor query string false "cursor pagination, taken from next/prev links"
 // @Success 200 {object} web.Response
+// @Failure 400 {object} web.Response
+// @Failure 403 {object} web.Response
 // @Failure 404 {object} web.Response
 // @Router /products [get]
 func (c *Product) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		products, err := c.service.GetAll(context.Background())
+		q, err := parseQuery(ctx)
 		if err != nil {
-			ctx.JSON(404, web.NewResponse(404, nil, err.Error()))
+			ctx.Error(err)
 			return
 		}
 
-		ctx.JSON(200, web.NewResponse(200, products, ""))
+		page, err := c.service.Search(ctx.Request.Context(), q)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		ctx.JSON(200, web.NewPaginatedResponse(200, page.Products, pagination(ctx, q, page)))
+	}
+}
+
+// GetProducts godoc
+// @Summary Gets a product by ID
+// @Tags Products
+// @Description get product by id; inactive products are only returned with includeInactive=true
+// @Produce json
+// @Param token header string true "token"
+// @Param id path integer true "product id"
+// @Param includeInactive query boolean false "include soft-deleted products; requires the admin role or the products:read-inactive scope"
+// @Param If-None-Match header string false "ETag already held by the client"
+// @Success 200 {object} web.Response
+// @Header 200 {string} ETag "product version"
+// @Success 304 "not modified"
+// @Failure 400 {object} web.Response
+// @Failure 403 {object} web.Response
+// @Failure 404 {object} web.Response
+// @Router /products/{id} [get]
+func (c *Product) Get() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+		if err != nil {
+			ctx.Error(apperrors.Validation("invalid_id"))
+			return
+		}
+
+		includeInactive, err := queryIncludeInactive(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		product, err := c.service.GetByID(ctx.Request.Context(), int(id), includeInactive)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		ctx.Header("ETag", etag(product))
+		if notModified(ctx, product) {
+			ctx.Status(http.StatusNotModified)
+			return
+		}
+		ctx.JSON(200, web.NewResponse(200, product, ""))
+	}
+}
+
+// GetProductByCode godoc
+// @Summary Gets a product by code
+// @Tags Products
+// @Description get product by code, ignoring case; inactive products are only returned with includeInactive=true, and an active product takes precedence over inactive ones with the same code
+// @Produce json
+// @Param token header string true "token"
+// @Param code path string true "product code"
+// @Param includeInactive query boolean false "include soft-deleted products; requires the admin role or the products:read-inactive scope"
+// @Param If-None-Match header string false "ETag already held by the client"
+// @Success 200 {object} web.Response
+// @Header 200 {string} ETag "product version"
+// @Success 304 "not modified"
+// @Failure 400 {object} web.Response
+// @Failure 403 {object} web.Response
+// @Failure 404 {object} web.Response
+// @Failure 409 {object} web.Response "more than one active product has the code"
+// @Router /products/by-code/{code} [get]
+func (c *Product) GetByCode() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		includeInactive, err := queryIncludeInactive(ctx)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		product, err := c.service.GetByCode(ctx.Request.Context(), ctx.Param("code"), includeInactive)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		ctx.Header("ETag", etag(product))
+		if notModified(ctx, product) {
+			ctx.Status(http.StatusNotModified)
+			return
+		}
+		ctx.JSON(200, web.NewResponse(200, product, ""))
 	}
 }
 
@@ -61,51 +171,27 @@ func (c *Product) GetAll() gin.HandlerFunc {
 // @Param token header string true "token"
 // @Param product body request true "Product to store"
 // @Success 200 {object} web.Response
+// @Header 200 {string} ETag "product version"
 // @Failure 400 {object} web.Response
+// @Failure 409 {object} web.Response
 // @Router /products [post]
 func (c *Product) Store() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req request
 
-		if err := ctx.ShouldBindJSON(&req); err != nil {
-			ctx.JSON(400, web.NewResponse(400, nil, err.Error()))
+		if err := bindJSON(ctx, &req); err != nil {
+			ctx.Error(apperrors.Validation("invalid_body", err.Error()))
 			return
 		}
 
-		{
-			if req.Name == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un nombre de producto"))
-				return
-			}
-			if req.Color == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un color para el producto"))
-				return
-			}
-			if req.Price == 0 {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un precio para el producto"))
-				return
-			}
-			if req.Stock == 0 {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un stock para el producto"))
-				return
-			}
-			if req.Code == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un código para el producto"))
-				return
-			}
-			if req.CreationDate == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer una fecha de creación para el producto"))
-				return
-			}
-		}
-
-		newProduct, err := c.service.Store(context.Background(), req.Name, req.Color, req.Price, req.Stock, req.Code, req.Published, req.CreationDate, req.Active)
+		newProduct, err := c.service.Store(ctx.Request.Context(), req.Name, req.Color, req.Price, req.Stock, req.Code, req.Published, req.CreationDate, req.Active)
 
 		if err != nil {
-			ctx.JSON(400, web.NewResponse(400, nil, err.Error()))
+			ctx.Error(err)
 			return
 		}
 
+		ctx.Header("ETag", etag(newProduct))
 		ctx.JSON(200, web.NewResponse(200, newProduct, ""))
 	}
 }
@@ -117,22 +203,30 @@ func (c *Product) Store() gin.HandlerFunc {
 // @Produce json
 // @Param token header string true "token"
 // @Param id path integer true "product id to be removed"
+// @Param If-Match header string false "ETag of the version being removed"
 // @Success 200 {object} web.Response
 // @Failure 400 {object} web.Response
 // @Failure 404 {object} web.Response
+// @Failure 412 {object} web.Response
 // @Router /products/{id} [delete]
 func (c *Product) Delete(hardDelete bool) gin.HandlerFunc {
 	if hardDelete {
 		return func(ctx *gin.Context) {
 			id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 			if err != nil {
-				ctx.JSON(400, web.NewResponse(400, nil, "invalid ID"))
+				ctx.Error(apperrors.Validation("invalid_id"))
+				return
+			}
+
+			version, err := c.ifMatch(ctx, int(id))
+			if err != nil {
+				ctx.Error(err)
 				return
 			}
 
-			products, err := c.service.HardDelete(context.Background(), int(id))
+			products, err := c.service.HardDelete(ctx.Request.Context(), int(id), version)
 			if err != nil {
-				ctx.JSON(404, web.NewResponse(404, nil, err.Error()))
+				ctx.Error(err)
 				return
 			}
 
@@ -143,13 +237,19 @@ func (c *Product) Delete(hardDelete bool) gin.HandlerFunc {
 		return func(ctx *gin.Context) {
 			id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 			if err != nil {
-				ctx.JSON(400, web.NewResponse(400, nil, "invalid ID"))
+				ctx.Error(apperrors.Validation("invalid_id"))
 				return
 			}
 
-			products, err := c.service.Delete(context.Background(), int(id))
+			version, err := c.ifMatch(ctx, int(id))
 			if err != nil {
-				ctx.JSON(404, web.NewResponse(404, nil, err.Error()))
+				ctx.Error(err)
+				return
+			}
+
+			products, err := c.service.Delete(ctx.Request.Context(), int(id), ctx.GetString(SubjectContextKey), version)
+			if err != nil {
+				ctx.Error(err)
 				return
 			}
 			ctx.JSON(200, web.NewResponse(200, products, ""))
@@ -157,6 +257,66 @@ func (c *Product) Delete(hardDelete bool) gin.HandlerFunc {
 	}
 }
 
+// TrashProducts godoc
+// @Summary List deleted products
+// @Tags Products
+// @Description get products that were soft deleted, with their deletion metadata; requires the admin role or the products:read-inactive scope
+// @Produce json
+// @Param token header string true "token"
+// @Success 200 {object} web.Response
+// @Failure 403 {object} web.Response
+// @Router /products/trash [get]
+func (c *Product) Trash() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		products, err := c.service.Trash(ctx.Request.Context())
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.JSON(200, web.NewResponse(200, products, ""))
+	}
+}
+
+// RestoreProducts godoc
+// @Summary Restores a deleted product based on given ID
+// @Tags Products
+// @Description restores soft deleted products
+// @Produce json
+// @Param token header string true "token"
+// @Param id path integer true "product id to be restored"
+// @Param If-Match header string false "ETag of the version being restored"
+// @Success 200 {object} web.Response
+// @Header 200 {string} ETag "product version"
+// @Failure 400 {object} web.Response
+// @Failure 404 {object} web.Response
+// @Failure 409 {object} web.Response
+// @Failure 412 {object} web.Response
+// @Router /products/{id}/restore [post]
+func (c *Product) Restore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+		if err != nil {
+			ctx.Error(apperrors.Validation("invalid_id"))
+			return
+		}
+
+		version, err := c.ifMatch(ctx, int(id))
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		product, err := c.service.Restore(ctx.Request.Context(), int(id), version)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+
+		ctx.Header("ETag", etag(product))
+		ctx.JSON(200, web.NewResponse(200, product, ""))
+	}
+}
+
 // UpdateProducts godoc
 // @Summary Updates product based on given ID
 // @Tags Products
@@ -165,111 +325,133 @@ func (c *Product) Delete(hardDelete bool) gin.HandlerFunc {
 // @Produce json
 // @Param token header string true "token"
 // @Param id path integer true "product id to be updated"
+// @Param If-Match header string false "ETag of the version being modified"
 // @Success 200 {object} web.Response
+// @Header 200 {string} ETag "product version"
 // @Failure 400 {object} web.Response
 // @Failure 404 {object} web.Response
+// @Failure 409 {object} web.Response
+// @Failure 412 {object} web.Response
 // @Router /products/{id} [put]
 func (c *Product) Update() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 		if err != nil {
-			ctx.JSON(400, web.NewResponse(400, nil, "invalid ID"))
+			ctx.Error(apperrors.Validation("invalid_id"))
 			return
 		}
 
 		var req request
-		if err = ctx.ShouldBindJSON(&req); err != nil {
-			ctx.JSON(400, web.NewResponse(400, nil, err.Error()))
+		if err = bindJSON(ctx, &req); err != nil {
+			ctx.Error(apperrors.Validation("invalid_body", err.Error()))
 			return
 		}
 
-		{
-			if req.Name == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un nombre de producto"))
-				return
-			}
-			if req.Color == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un color para el producto"))
-				return
-			}
-			if req.Price == 0 {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un precio para el producto"))
-				return
-			}
-			if req.Stock == 0 {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un stock para el producto"))
-				return
-			}
-			if req.Code == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un código para el producto"))
-				return
-			}
-			if req.CreationDate == "" {
-				ctx.JSON(400, web.NewResponse(400, nil, "debe proveer una fecha de creación para el producto"))
-				return
-			}
+		version, err := c.ifMatch(ctx, int(id))
+		if err != nil {
+			ctx.Error(err)
+			return
 		}
 
-		productUpdated, err := c.service.Update(context.Background(), int(id), req.Name, req.Color, req.Price, req.Stock, req.Code, req.Published, req.CreationDate, req.Active)
+		productUpdated, err := c.service.Update(ctx.Request.Context(), int(id), req.Name, req.Color, req.Price, req.Stock, req.Code, req.Published, req.CreationDate, req.Active, ctx.GetString(SubjectContextKey), version)
 
 		if err != nil {
-			ctx.JSON(404, web.NewResponse(404, nil, err.Error()))
+			ctx.Error(err)
 			return
 		}
 
+		ctx.Header("ETag", etag(productUpdated))
 		ctx.JSON(200, web.NewResponse(200, productUpdated, ""))
 	}
 }
 
-// UpdateNameAndPriceProducts godoc
-// @Summary Updates name and price of product based on given ID
+// PatchProducts godoc
+// @Summary Partially updates a product
 // @Tags Products
-// @Description updates name and price of products
+// @Description applies a JSON Merge Patch (RFC 7396; also accepted as application/json) or a JSON Patch (RFC 6902) to any product field. The result is validated as a whole product; id and version are read only. A failed test operation returns 409.
 // @Accept json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
 // @Produce json
 // @Param token header string true "token"
 // @Param id path integer true "product id to be updated"
+// @Param If-Match header string false "ETag of the version being modified"
+// @Param patch body object true "merge patch object or JSON Patch operation list"
 // @Success 200 {object} web.Response
+// @Header 200 {string} ETag "product version"
 // @Failure 400 {object} web.Response
 // @Failure 404 {object} web.Response
+// @Failure 409 {object} web.Response
+// @Failure 412 {object} web.Response
+// @Failure 415 {object} web.Response
 // @Router /products/{id} [patch]
-func (c *Product) UpdateNameAndPrice() gin.HandlerFunc {
+func (c *Product) Patch() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 		if err != nil {
-			ctx.JSON(400, web.NewResponse(400, nil, "invalid ID"))
+			ctx.Error(apperrors.Validation("invalid_id"))
 			return
 		}
 
-		var req request
-		if err := ctx.ShouldBindJSON(&req); err != nil {
-			ctx.JSON(400, web.NewResponse(400, nil, err.Error()))
+		patch, err := bindPatch(ctx)
+		if err != nil {
+			ctx.Error(err)
 			return
 		}
 
-		if req.Name == "" {
-			ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un nombre de producto"))
-			return
-		}
-		if req.Price == 0 {
-			ctx.JSON(400, web.NewResponse(400, nil, "debe proveer un precio para el producto"))
+		version, err := c.ifMatch(ctx, int(id))
+		if err != nil {
+			ctx.Error(err)
 			return
 		}
 
-		updatedProduct, err := c.service.UpdateNameAndPrice(context.Background(), int(id), req.Name, req.Price)
+		updatedProduct, err := c.service.Patch(ctx.Request.Context(), int(id), patch, ctx.GetString(SubjectContextKey), version)
 		if err != nil {
-			ctx.JSON(404, web.NewResponse(404, nil, err.Error()))
+			ctx.Error(err)
 			return
 		}
 
+		ctx.Header("ETag", etag(updatedProduct))
 		ctx.JSON(200, web.NewResponse(200, updatedProduct, ""))
 	}
 }
 
-func (c *Product) ValidateToken(ctx *gin.Context) {
-	token := ctx.GetHeader("token")
-	if token != os.Getenv("TOKEN") {
-		ctx.AbortWithStatusJSON(401, web.NewResponse(401, nil, "no tiene permisos para realizar la petición solicitada"))
-		return
+// bindJSON decodifica el body en obj dentro de su propio span, para distinguir en la traza el
+// tiempo de lectura del body del de la lógica del servicio.
+func bindJSON(ctx *gin.Context, obj interface{}) error {
+	_, span := tracing.Start(ctx.Request.Context(), "bindJSON")
+	err := ctx.ShouldBindJSON(obj)
+	tracing.End(span, err)
+	return err
+}
+
+// acceptPatch enumera los formatos que acepta PATCH, para el header Accept-Patch (RFC 5789).
+const acceptPatch = "application/merge-patch+json, application/json-patch+json"
+
+// bindPatch lee el body de un PATCH según su Content-Type. application/json se interpreta como
+// merge patch, que es compatible con el cuerpo {"name", "price"} que PATCH aceptaba antes.
+func bindPatch(ctx *gin.Context) (patch products.Patch, err error) {
+	_, span := tracing.Start(ctx.Request.Context(), "bindPatch")
+	defer func() { tracing.End(span, err) }()
+
+	contentType := ctx.ContentType()
+	var decode func([]byte) (products.Patch, error)
+	switch contentType {
+	case "application/merge-patch+json", "application/json":
+		decode = func(body []byte) (products.Patch, error) { return jsonpatch.NewMergePatch(body) }
+	case "application/json-patch+json":
+		decode = func(body []byte) (products.Patch, error) { return jsonpatch.Decode(body) }
+	default:
+		ctx.Header("Accept-Patch", acceptPatch)
+		return nil, apperrors.UnsupportedMediaType("unsupported_patch_type", contentType)
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return nil, apperrors.Validation("invalid_body", err.Error())
+	}
+	if patch, err = decode(body); err != nil {
+		return nil, apperrors.Validation("invalid_patch", err.Error())
 	}
+	return patch, nil
 }
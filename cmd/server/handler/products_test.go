@@ -4,12 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/apikeys"
 	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/internal/products"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/audit"
+	"github.com/palomavs/go-web-II/pkg/jsonpatch"
+	"github.com/palomavs/go-web-II/pkg/jwtauth"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/palomavs/go-web-II/pkg/web"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -34,41 +45,92 @@ func (s *productServiceMock) GetAll(ctx context.Context) ([]domain.Product, erro
 	return args.Get(0).([]domain.Product), args.Error(1)
 }
 
+func (s *productServiceMock) Search(ctx context.Context, q products.Query) (products.Page, error) {
+	args := s.Called(ctx, q)
+	return args.Get(0).(products.Page), args.Error(1)
+}
+
+func (s *productServiceMock) GetByID(ctx context.Context, id int, includeInactive bool) (domain.Product, error) {
+	args := s.Called(ctx, id, includeInactive)
+	return args.Get(0).(domain.Product), args.Error(1)
+}
+
+func (s *productServiceMock) GetByCode(ctx context.Context, code string, includeInactive bool) (domain.Product, error) {
+	args := s.Called(ctx, code, includeInactive)
+	return args.Get(0).(domain.Product), args.Error(1)
+}
+
 func (s *productServiceMock) Store(ctx context.Context, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error) {
 	args := s.Called(ctx, name, color, price, stock, code, published, creationDate, active)
 	return args.Get(0).(domain.Product), args.Error(1)
 }
 
-func (s *productServiceMock) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error) {
-	args := s.Called(ctx, id, name, color, price, stock, code, published, creationDate, active)
+func (s *productServiceMock) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedBy string, version int) (domain.Product, error) {
+	args := s.Called(ctx, id, name, color, price, stock, code, published, creationDate, active, deletedBy, version)
 	return args.Get(0).(domain.Product), args.Error(1)
 }
 
-func (s *productServiceMock) UpdateNameAndPrice(ctx context.Context, id int, name string, price float64) (domain.Product, error) {
-	args := s.Called(ctx, id, name, price)
+func (s *productServiceMock) Patch(ctx context.Context, id int, patch products.Patch, deletedBy string, version int) (domain.Product, error) {
+	args := s.Called(ctx, id, patch, deletedBy, version)
 	return args.Get(0).(domain.Product), args.Error(1)
 }
 
-func (s *productServiceMock) HardDelete(ctx context.Context, id int) ([]domain.Product, error) {
-	args := s.Called(ctx, id)
+func (s *productServiceMock) HardDelete(ctx context.Context, id int, version int) ([]domain.Product, error) {
+	args := s.Called(ctx, id, version)
 	return args.Get(0).([]domain.Product), args.Error(1)
 }
 
-func (s *productServiceMock) Delete(ctx context.Context, id int) ([]domain.Product, error) {
-	args := s.Called(ctx, id)
+func (s *productServiceMock) Delete(ctx context.Context, id int, deletedBy string, version int) ([]domain.Product, error) {
+	args := s.Called(ctx, id, deletedBy, version)
 	return args.Get(0).([]domain.Product), args.Error(1)
 }
 
-func StartServer(handler *Product) *gin.Engine {
+func (s *productServiceMock) Trash(ctx context.Context) ([]domain.Product, error) {
+	args := s.Called(ctx)
+	return args.Get(0).([]domain.Product), args.Error(1)
+}
+
+func (s *productServiceMock) Restore(ctx context.Context, id int, version int) (domain.Product, error) {
+	args := s.Called(ctx, id, version)
+	return args.Get(0).(domain.Product), args.Error(1)
+}
+
+func (s *productServiceMock) Bulk(ctx context.Context, ops []products.BulkOperation, deletedBy string, opts products.BulkOptions) ([]products.BulkResult, error) {
+	args := s.Called(ctx, ops, deletedBy, opts)
+	return args.Get(0).([]products.BulkResult), args.Error(1)
+}
+
+func (s *productServiceMock) Import(ctx context.Context, file products.CSVImport, deletedBy string, dryRun bool) (products.ImportReport, error) {
+	args := s.Called(ctx, file, deletedBy, dryRun)
+	return args.Get(0).(products.ImportReport), args.Error(1)
+}
+
+func (s *productServiceMock) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	args := s.Called(ctx, retention)
+	return args.Int(0), args.Error(1)
+}
+
+// StartServer registra las rutas sin autenticación; roles son los de la credencial que simula
+// haber autorizado Authorize.
+func StartServer(handler *Product, roles ...string) *gin.Engine {
 	r := gin.Default()
+	r.Use(web.ErrorHandler())
+	r.Use(func(ctx *gin.Context) { ctx.Set(RolesContextKey, roles) })
 	pr := r.Group("/products")
 	{
-		pr.GET("/", handler.ValidateToken, handler.GetAll())
-		pr.POST("/", handler.ValidateToken, handler.Store())
-		pr.PUT("/:id", handler.ValidateToken, handler.Update())
-		pr.DELETE("/:id", handler.ValidateToken, handler.Delete(false))
-		pr.DELETE("/hardDelete/:id", handler.ValidateToken, handler.Delete(true))
-		pr.PATCH("/:id", handler.ValidateToken, handler.UpdateNameAndPrice())
+		pr.GET("/", handler.GetAll())
+		pr.GET("/trash", handler.Trash())
+		pr.GET("/export", handler.Export())
+		pr.GET("/by-code/:code", handler.GetByCode())
+		pr.GET("/:id", handler.Get())
+		pr.POST("/", handler.Store())
+		pr.POST("/bulk", handler.Bulk())
+		pr.POST("/import", handler.Import())
+		pr.PUT("/:id", handler.Update())
+		pr.DELETE("/:id", handler.Delete(false))
+		pr.DELETE("/hardDelete/:id", handler.Delete(true))
+		pr.PATCH("/:id", handler.Patch())
+		pr.POST("/:id/restore", handler.Restore())
 	}
 	return r
 }
@@ -85,7 +147,7 @@ func TestUpdate_OK(t *testing.T) {
 	serviceMock := new(productServiceMock)
 
 	newprod := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true}
-	serviceMock.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(newprod, nil)
+	serviceMock.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, products.AnyVersion).Return(newprod, nil)
 	productHandler := NewProduct(serviceMock)
 	router := StartServer(productHandler)
 
@@ -108,7 +170,7 @@ func TestUpdate_OK(t *testing.T) {
 func TestDelete_OK(t *testing.T) {
 	serviceMock := new(productServiceMock)
 	products := []domain.Product{{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: false}}
-	serviceMock.On("Delete", mock.Anything, mock.Anything).Return(products, nil)
+	serviceMock.On("Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(products, nil)
 	productHandler := NewProduct(serviceMock)
 	router := StartServer(productHandler)
 
@@ -125,3 +187,428 @@ func TestDelete_OK(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.Equal(t, products, res.Data)
 }
+
+func TestGet_OK(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	product := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: false}
+	serviceMock.On("GetByID", mock.Anything, 1, true).Return(product, nil)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler, domain.RoleAdmin)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/1?includeInactive=true", nil)
+	router.ServeHTTP(rr, req)
+
+	type resp struct {
+		Data domain.Product `json:"data"`
+	}
+
+	res := new(resp)
+	err := json.Unmarshal(rr.Body.Bytes(), res)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, product, res.Data)
+}
+
+func TestGet_ETag(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	product := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true, Version: 3}
+	serviceMock.On("GetByID", mock.Anything, 1, false).Return(product, nil)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/1", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+
+	req, rr = createRequestTest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("If-None-Match", `W/"3"`)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String(), "un 304 no lleva cuerpo")
+
+	req, rr = createRequestTest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("If-None-Match", `"2"`)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestUpdate_IfMatch(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	current := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true, Version: 5}
+	updated := current
+	updated.Version = 6
+	serviceMock.On("GetByID", mock.Anything, 1, true).Return(current, nil)
+	serviceMock.On("Patch", mock.Anything, 1, mock.Anything, mock.Anything, 5).Return(updated, nil)
+	serviceMock.On("Patch", mock.Anything, 1, mock.Anything, mock.Anything, 4).Return(domain.Product{}, apperrors.PreconditionFailed("version_mismatch", 1))
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+	body := []byte(`{"name":"prod-2","price":10}`)
+
+	req, rr := createRequestTest(http.MethodPatch, "/products/1", body)
+	req.Header.Set("If-Match", `"4"`)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	// Con varios ETags se usa el que coincide con la versión actual
+	req, rr = createRequestTest(http.MethodPatch, "/products/1", body)
+	req.Header.Set("If-Match", `"1", "5"`)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"6"`, rr.Header().Get("ETag"))
+
+	// Los ETags débiles nunca coinciden con If-Match
+	req, rr = createRequestTest(http.MethodPatch, "/products/1", body)
+	req.Header.Set("If-Match", `W/"5"`)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+}
+
+func TestPatch_ContentType(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	product := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true, Version: 2}
+	serviceMock.On("Patch", mock.Anything, 1, jsonpatch.MergePatch(`{"stock":1}`), mock.Anything, products.AnyVersion).Return(product, nil)
+	serviceMock.On("Patch", mock.Anything, 1, jsonpatch.Patch{{Op: "remove", Path: "/color"}}, mock.Anything, products.AnyVersion).Return(domain.Product{}, apperrors.Validation("field.required"))
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	for _, contentType := range []string{"application/json", "application/merge-patch+json"} {
+		req, rr := createRequestTest(http.MethodPatch, "/products/1", []byte(`{"stock":1}`))
+		req.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, contentType)
+	}
+
+	req, rr := createRequestTest(http.MethodPatch, "/products/1", []byte(`[{"op":"remove","path":"/color"}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req, rr = createRequestTest(http.MethodPatch, "/products/1", []byte(`[{"op":"rename","path":"/color"}]`))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req, rr = createRequestTest(http.MethodPatch, "/products/1", []byte(`stock=1`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, acceptPatch, rr.Header().Get("Accept-Patch"))
+	serviceMock.AssertNumberOfCalls(t, "Patch", 3)
+}
+
+func TestGet_NotFound(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	serviceMock.On("GetByID", mock.Anything, 2, false).Return(domain.Product{}, apperrors.NotFound("product_not_found", 2))
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/2", nil)
+	router.ServeHTTP(rr, req)
+
+	res := new(web.Response)
+	err := json.Unmarshal(rr.Body.Bytes(), res)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "404", res.Code)
+	assert.Equal(t, "producto de id 2 no encontrado", res.Error)
+
+	req, rr = createRequestTest(http.MethodGet, "/products/2", nil)
+	req.Header.Set("Accept-Language", "en")
+	router.ServeHTTP(rr, req)
+	res = new(web.Response)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), res))
+	assert.Equal(t, "product with id 2 not found", res.Error)
+	assert.Equal(t, "en", rr.Header().Get("Content-Language"))
+}
+
+func TestInactiveProducts_RequireAdmin(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	product := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: false}
+	serviceMock.On("GetByID", mock.Anything, 1, true).Return(product, nil)
+	serviceMock.On("GetByCode", mock.Anything, "AAA", true).Return(product, nil)
+	serviceMock.On("Search", mock.Anything, mock.Anything).Return(products.Page{Products: []domain.Product{product}, Total: 1}, nil)
+	serviceMock.On("GetAll", mock.Anything).Return([]domain.Product{product}, nil)
+	serviceMock.On("Trash", mock.Anything).Return([]domain.Product{product}, nil)
+	productHandler := NewProduct(serviceMock)
+
+	keys := apikeys.NewService(apikeys.NewRepository(store.New(store.FileType, filepath.Join(t.TempDir(), "apikeys.json"))))
+	verifier := jwtauth.NewVerifier([]jwtauth.Key{{Public: jwtSecret}}, "products-api", "")
+	auth := NewAuth(keys, "", verifier, audit.NewLogger(&bytes.Buffer{}))
+	r := gin.Default()
+	r.Use(web.ErrorHandler())
+	pr := r.Group("/products", auth.Authorize(ProductPolicy))
+	{
+		pr.GET("/", productHandler.GetAll())
+		pr.GET("/trash", productHandler.Trash())
+		pr.GET("/export", productHandler.Export())
+		pr.GET("/by-code/:code", productHandler.GetByCode())
+		pr.GET("/:id", productHandler.Get())
+	}
+	readOnly, _, err := keys.Issue(context.Background(), "catalogo", []string{domain.ScopeProductsRead}, nil)
+	assert.Nil(t, err, "no debería dar error")
+	withInactive, _, err := keys.Issue(context.Background(), "auditoria", []string{domain.ScopeProductsRead, domain.ScopeProductsReadInactive}, nil)
+	assert.Nil(t, err, "no debería dar error")
+
+	urls := []string{"/products/1?includeInactive=true", "/products/by-code/AAA?includeInactive=true", "/products/?active=false", "/products/?includeInactive=true", "/products/export?includeInactive=true", "/products/trash"}
+	credentials := []struct {
+		name, header, value string
+		status              int
+	}{
+		{"viewer", "Authorization", bearerToken(t, "products-api", "", domain.RoleViewer), http.StatusForbidden},
+		{"api key de lectura", "token", readOnly, http.StatusForbidden},
+		{"admin", "Authorization", bearerToken(t, "products-api", "", domain.RoleAdmin), http.StatusOK},
+		{"api key con products:read-inactive", "token", withInactive, http.StatusOK},
+	}
+	for _, c := range credentials {
+		for _, url := range urls {
+			req, rr := createRequestTest(http.MethodGet, url, nil)
+			req.Header.Set(c.header, c.value)
+			r.ServeHTTP(rr, req)
+			assert.Equal(t, c.status, rr.Code, "%s: %s", c.name, url)
+		}
+	}
+
+	// Sin el parámetro, los lectores siguen consultando los productos activos
+	serviceMock.On("GetByID", mock.Anything, 1, false).Return(product, nil)
+	req, rr := createRequestTest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("Authorization", bearerToken(t, "products-api", "", domain.RoleViewer))
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "deben ser iguales")
+}
+
+func TestGetByCode(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	product := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true, Version: 2}
+	serviceMock.On("GetByCode", mock.Anything, "aaa", false).Return(product, nil)
+	serviceMock.On("GetByCode", mock.Anything, "BBB", false).Return(domain.Product{}, apperrors.NotFound("product_code_not_found", "BBB"))
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/by-code/aaa", nil)
+	router.ServeHTTP(rr, req)
+
+	type resp struct {
+		Data domain.Product `json:"data"`
+	}
+	res := new(resp)
+	err := json.Unmarshal(rr.Body.Bytes(), res)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, product, res.Data)
+	assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
+
+	req, rr = createRequestTest(http.MethodGet, "/products/by-code/BBB", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetAll_Paginated(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	list := []domain.Product{{Id: 3, Name: "prod-3", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true}}
+	active, priceMin := true, 10.0
+	expectedQuery := products.Query{Colors: []string{"celeste", "azul"}, PriceMin: &priceMin, Active: &active, Sort: []products.SortKey{{Field: "price", Desc: true}}, Limit: 1, Offset: 2}
+	serviceMock.On("Search", mock.Anything, expectedQuery).Return(products.Page{Products: list, Total: 5, Offset: 2, Limit: 1, HasNext: true, HasPrev: true}, nil)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/?color=celeste,azul&priceMin=10&sort=-price&limit=1&offset=2", nil)
+	router.ServeHTTP(rr, req)
+
+	type resp struct {
+		Data       []domain.Product `json:"data"`
+		Pagination web.Pagination   `json:"pagination"`
+	}
+
+	res := new(resp)
+	err := json.Unmarshal(rr.Body.Bytes(), res)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, list, res.Data)
+	assert.Equal(t, 5, res.Pagination.Total)
+	assert.Equal(t, "/products/?color=celeste%2Cazul&limit=1&offset=3&priceMin=10&sort=-price", res.Pagination.Next)
+	assert.Equal(t, "/products/?color=celeste%2Cazul&limit=1&offset=1&priceMin=10&sort=-price", res.Pagination.Prev)
+}
+
+func TestGetAll_InvalidQuery(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/?priceMin=barato", nil)
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	serviceMock.AssertNotCalled(t, "Search", mock.Anything, mock.Anything)
+}
+
+func TestTrash_OK(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	deletedAt := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	list := []domain.Product{{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: false, DeletedAt: &deletedAt, DeletedBy: "key-1"}}
+	serviceMock.On("Trash", mock.Anything).Return(list, nil)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/trash", nil)
+	router.ServeHTTP(rr, req)
+
+	type resp struct {
+		Data []domain.Product `json:"data"`
+	}
+
+	res := new(resp)
+	err := json.Unmarshal(rr.Body.Bytes(), res)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, list, res.Data)
+	serviceMock.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRestore(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	restored := domain.Product{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true, Version: 4}
+	serviceMock.On("Restore", mock.Anything, 1, 3).Return(restored, nil)
+	serviceMock.On("Restore", mock.Anything, 2, products.AnyVersion).Return(domain.Product{}, apperrors.Conflict("product_not_deleted", 2))
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodPost, "/products/1/restore", nil)
+	req.Header.Set("If-Match", `"3"`)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+
+	req, rr = createRequestTest(http.MethodPost, "/products/2/restore", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+func TestBulk(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	product := domain.Product{Id: 7, Name: "prod-7", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true, Version: 1}
+	ops := []products.BulkOperation{
+		{Op: products.OpCreate, Product: domain.Product{Name: "prod-7", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true}, Version: products.AnyVersion},
+		{Op: products.OpDelete, Product: domain.Product{Id: 2}, Version: 3},
+		{Op: products.OpDelete, Product: domain.Product{Id: 3}, Version: products.AnyVersion},
+	}
+	results := []products.BulkResult{{Product: product, Applied: true}, {Err: apperrors.PreconditionFailed("version_mismatch", 2)}, {}}
+	serviceMock.On("Bulk", mock.Anything, ops, "", products.BulkOptions{}).Return(results, nil)
+	serviceMock.On("Bulk", mock.Anything, ops[1:], "", products.BulkOptions{Atomic: true}).Return(results[1:], nil)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	type resp struct {
+		Data  []bulkResult `json:"data"`
+		Error string       `json:"error"`
+	}
+
+	body := `{"operations": [
+		{"op": "create", "product": {"name": "prod-7", "color": "celeste", "price": 852.33, "stock": 100, "code": "AAA", "published": true, "creationDate": "3-5-2005", "active": true}},
+		{"op": "delete", "id": 2, "version": 3},
+		{"op": "delete", "id": 3}]}`
+	req, rr := createRequestTest(http.MethodPost, "/products/bulk", []byte(body))
+	router.ServeHTTP(rr, req)
+
+	res := new(resp)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), res))
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	assert.Equal(t, []int{http.StatusOK, http.StatusPreconditionFailed, http.StatusFailedDependency}, []int{res.Data[0].Status, res.Data[1].Status, res.Data[2].Status})
+	assert.Equal(t, &product, res.Data[0].Data)
+
+	// Un lote atómico descartado responde con el código de su primera falla
+	req, rr = createRequestTest(http.MethodPost, "/products/bulk", []byte(`{"atomic": true, "operations": [{"op": "delete", "id": 2, "version": 3}, {"op": "delete", "id": 3}]}`))
+	router.ServeHTTP(rr, req)
+	res = new(resp)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), res))
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	assert.NotEmpty(t, res.Error)
+	assert.Len(t, res.Data, 2)
+
+	req, rr = createRequestTest(http.MethodPost, "/products/bulk", []byte(`{"operations": []}`))
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestExport(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	list := []domain.Product{
+		{Id: 1, Name: "prod-1", Color: "celeste", Price: 852.33, Stock: 100, Code: "AAA", Published: true, CreationDate: "3-5-2005", Active: true},
+		{Id: 2, Name: "prod-2", Color: "azul", Price: 10, Stock: 1, Code: "BBB", Published: false, CreationDate: "3-5-2005", Active: false},
+	}
+	serviceMock.On("GetAll", mock.Anything).Return(list, nil)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	req, rr := createRequestTest(http.MethodGet, "/products/export?format=csv", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "code,name,color,price,stock,published,creationDate,active\nAAA,prod-1,celeste,852.33,100,true,3-5-2005,true\n", rr.Body.String())
+
+	req, rr = createRequestTest(http.MethodGet, "/products/export?format=xlsx", nil)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestImport(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	product := domain.Product{Name: "prod-1", Color: "celeste", Price: 852.33, Code: "AAA", CreationDate: "3-5-2005", Active: true}
+	file := products.CSVImport{Columns: []string{"code", "name", "color", "price", "creationDate"}, Rows: []products.ImportRow{
+		{Line: 2, Product: domain.Product{Name: "prod-1", Color: "celeste", Price: 852.33, Code: "AAA", CreationDate: "3-5-2005"}},
+	}}
+	serviceMock.On("Import", mock.Anything, file, mock.Anything, true).Return(products.ImportReport{Results: []products.ImportResult{{Line: 2, Action: products.ImportCreate, Product: product}}, Created: 1}, nil)
+	productHandler := NewProduct(serviceMock)
+	router := StartServer(productHandler)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "products.csv")
+	part.Write([]byte("SKU,name,color,price,creationDate\nAAA,prod-1,celeste,852.33,3-5-2005\n"))
+	form.WriteField("mapping", `{"SKU": "code"}`)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/products/import?dryRun=true", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	type resp struct {
+		Data importResponse `json:"data"`
+	}
+	res := new(resp)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), res))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, res.Data.DryRun)
+	assert.Equal(t, 1, res.Data.Created)
+	assert.Equal(t, &product, res.Data.Rows[0].Data)
+}
+
+func TestImport_RowErrorsLocalized(t *testing.T) {
+	serviceMock := new(productServiceMock)
+	rowErr := apperrors.Validation("validation_failed")
+	rowErr.Fields = []apperrors.FieldError{{Field: "price", Code: "number"}}
+	serviceMock.On("Import", mock.Anything, mock.Anything, mock.Anything, true).Return(products.ImportReport{Results: []products.ImportResult{{Line: 2, Err: rowErr}}, Failed: 1}, nil)
+	router := StartServer(NewProduct(serviceMock))
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "products.csv")
+	part.Write([]byte("code,price\nAAA,barato\n"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/products/import?dryRun=true", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Accept-Language", "en")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	type resp struct {
+		Data importResponse `json:"data"`
+	}
+	res := new(resp)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), res))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "price: must be a number", res.Data.Rows[0].Error)
+	assert.Equal(t, []apperrors.FieldError{{Field: "price", Code: "number", Message: "must be a number"}}, res.Data.Rows[0].Errors)
+}
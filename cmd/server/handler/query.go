@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/internal/products"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/web"
+)
+
+// parseQuery traduce los query params de GET /products a una products.Query.
+// Salvo que se pida active o includeInactive=true, solo se listan productos activos; pedir los
+// dados de baja exige InactiveRule.
+func parseQuery(ctx *gin.Context) (products.Query, error) {
+	var q products.Query
+	var err error
+
+	q.Name = ctx.Query("name")
+
+	for _, value := range ctx.QueryArray("color") {
+		for _, color := range strings.Split(value, ",") {
+			if color = strings.TrimSpace(color); color != "" {
+				q.Colors = append(q.Colors, color)
+			}
+		}
+	}
+
+	if q.PriceMin, err = queryFloat(ctx, "priceMin"); err != nil {
+		return q, err
+	}
+	if q.PriceMax, err = queryFloat(ctx, "priceMax"); err != nil {
+		return q, err
+	}
+	if q.StockBelow, err = queryInt(ctx, "stockBelow"); err != nil {
+		return q, err
+	}
+	if q.Published, err = queryBool(ctx, "published"); err != nil {
+		return q, err
+	}
+	if q.Active, err = queryBool(ctx, "active"); err != nil {
+		return q, err
+	}
+
+	include, err := queryIncludeInactive(ctx)
+	if err != nil {
+		return q, err
+	}
+	if q.Active != nil && !*q.Active && !permitted(ctx, InactiveRule) {
+		return q, errInactiveForbidden()
+	}
+	if q.Active == nil && !include {
+		active := true
+		q.Active = &active
+	}
+
+	for _, param := range []string{"creationDateFrom", "creationDateTo"} {
+		value, ok := ctx.GetQuery(param)
+		if !ok {
+			continue
+		}
+		date, err := products.ParseDate(value)
+		if err != nil {
+			return q, apperrors.Validation("invalid_param", param)
+		}
+		if param == "creationDateFrom" {
+			q.CreatedFrom = &date
+		} else {
+			q.CreatedTo = &date
+		}
+	}
+
+	if q.Sort, err = products.ParseSort(ctx.Query("sort")); err != nil {
+		return q, err
+	}
+
+	if limit, err := queryInt(ctx, "limit"); err != nil {
+		return q, err
+	} else if limit != nil {
+		q.Limit = *limit
+	}
+	if offset, err := queryInt(ctx, "offset"); err != nil {
+		return q, err
+	} else if offset != nil {
+		q.Offset = *offset
+	}
+	q.Cursor = ctx.Query("cursor")
+
+	return q, nil
+}
+
+// pagination arma los enlaces a la página siguiente y anterior conservando el resto de los filtros.
+// Si la petición usó cursor, los enlaces también usan cursor; si no, offset.
+func pagination(ctx *gin.Context, q products.Query, page products.Page) web.Pagination {
+	p := web.Pagination{Total: page.Total, Count: len(page.Products), Offset: page.Offset, Limit: page.Limit}
+
+	link := func(set map[string]string) string {
+		values := ctx.Request.URL.Query()
+		for _, key := range []string{"cursor", "offset"} {
+			values.Del(key)
+		}
+		for key, value := range set {
+			values.Set(key, value)
+		}
+		u := url.URL{Path: ctx.Request.URL.Path, RawQuery: values.Encode()}
+		return u.String()
+	}
+
+	if q.Cursor != "" {
+		if page.HasNext && page.NextCursor != "" {
+			p.Next = link(map[string]string{"cursor": page.NextCursor})
+		}
+		if page.HasPrev && page.PrevCursor != "" {
+			p.Prev = link(map[string]string{"cursor": page.PrevCursor})
+		}
+		return p
+	}
+
+	if page.HasNext && page.Limit > 0 {
+		p.Next = link(map[string]string{"offset": strconv.Itoa(page.Offset + page.Limit)})
+	}
+	if page.HasPrev {
+		prev := page.Offset - page.Limit
+		if page.Limit == 0 || prev < 0 {
+			prev = 0
+		}
+		p.Prev = link(map[string]string{"offset": strconv.Itoa(prev)})
+	}
+	return p
+}
+
+func queryFloat(ctx *gin.Context, key string) (*float64, error) {
+	value, ok := ctx.GetQuery(key)
+	if !ok {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, apperrors.Validation("invalid_param", key)
+	}
+	return &f, nil
+}
+
+func queryInt(ctx *gin.Context, key string) (*int, error) {
+	value, ok := ctx.GetQuery(key)
+	if !ok {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, apperrors.Validation("invalid_param", key)
+	}
+	return &i, nil
+}
+
+// queryIncludeInactive lee el parámetro includeInactive, que solo acepta true de quien cumple InactiveRule.
+func queryIncludeInactive(ctx *gin.Context) (bool, error) {
+	include, err := queryBool(ctx, "includeInactive")
+	if err != nil || include == nil {
+		return false, err
+	}
+	if *include && !permitted(ctx, InactiveRule) {
+		return false, errInactiveForbidden()
+	}
+	return *include, nil
+}
+
+func errInactiveForbidden() error {
+	return apperrors.Forbidden("forbidden_scope", InactiveRule.Permission)
+}
+
+func queryBool(ctx *gin.Context, key string) (*bool, error) {
+	value, ok := ctx.GetQuery(key)
+	if !ok {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, apperrors.Validation("invalid_param", key)
+	}
+	return &b, nil
+}
@@ -1,15 +1,35 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/palomavs/go-web-II/cmd/server/handler"
 	"github.com/palomavs/go-web-II/docs"
+	"github.com/palomavs/go-web-II/internal/apikeys"
+	"github.com/palomavs/go-web-II/internal/config"
+	"github.com/palomavs/go-web-II/internal/domain"
 	"github.com/palomavs/go-web-II/internal/products"
+	"github.com/palomavs/go-web-II/pkg/audit"
+	"github.com/palomavs/go-web-II/pkg/health"
+	"github.com/palomavs/go-web-II/pkg/i18n"
+	"github.com/palomavs/go-web-II/pkg/jwtauth"
+	"github.com/palomavs/go-web-II/pkg/logging"
+	"github.com/palomavs/go-web-II/pkg/metrics"
+	"github.com/palomavs/go-web-II/pkg/server"
 	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/palomavs/go-web-II/pkg/tracing"
+	"github.com/palomavs/go-web-II/pkg/web"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 )
@@ -25,32 +45,186 @@ import (
 // @license.name Apache 2.0
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 func main() {
-	err := godotenv.Load()
+	// El .env es opcional: sirve para desarrollo, en producción se usan variables o el archivo de configuración
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("error al intentar cargar el archivo .env: ", err)
+	}
+
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatal("error al intentar cargar el archivo .env")
+		log.Fatal(err)
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := i18n.Default.SetDefaultLocale(cfg.Locale); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Log.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
 	}
 
-	db := store.New(store.FileType, "./products.json")
-	repository := products.NewRepository(db)
-	service := products.NewService(repository)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: cfg.Tracing.ServiceName,
+		Exporter:    cfg.Tracing.Exporter,
+		Target:      cfg.Tracing.Target,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatal("error al intentar configurar el tracing: ", err)
+	}
+
+	m := metrics.New()
+	db := store.New(store.Type(cfg.Store.Type), cfg.Store.File)
+
+	var repository products.Repository
+	codeScope := products.CodeScope(cfg.Store.UniqueCodeScope)
+	switch s := db.(type) {
+	case *store.SQLiteStore:
+		sqlDB, err := s.DB()
+		if err != nil {
+			log.Fatal("error al intentar abrir la base de datos: ", err)
+		}
+		// El repositorio SQL no pasa por Store: se miden sus operaciones y se exponen las estadísticas del pool
+		repository = products.NewInstrumentedRepository(products.NewSQLRepository(sqlDB, codeScope), "products", m)
+		m.Register(collectors.NewDBStatsCollector(sqlDB, "products"))
+	default:
+		repository = products.NewRepository(tracing.Store("products", m.InstrumentStore("products", db)), codeScope)
+	}
+	repository = products.NewTracedRepository(repository)
+	m.Register(metrics.NewProductsCollector(repository.Count, 2*time.Second))
+	service := products.NewTracedService(products.NewService(repository))
 	pc := handler.NewProduct(service)
 
-	r := gin.Default()
+	keysDB := store.New(store.FileType, cfg.Store.APIKeysFile)
+	keyService := apikeys.NewService(apikeys.NewRepository(tracing.Store("apikeys", m.InstrumentStore("apikeys", keysDB))))
+	kc := handler.NewAPIKey(keyService)
+	if cfg.Auth.Token != "" {
+		log.Print("TOKEN está definido: se acepta como clave con todos los scopes; emita API keys y quítelo")
+	}
+
+	var verifier *jwtauth.Verifier
+	if len(cfg.Auth.JWTKeyFiles) > 0 {
+		var keys []jwtauth.Key
+		for _, file := range cfg.Auth.JWTKeyFiles {
+			k, err := jwtauth.LoadFile(file)
+			if err != nil {
+				log.Fatal("error al intentar cargar las claves JWT: ", err)
+			}
+			keys = append(keys, k...)
+		}
+		verifier = jwtauth.NewVerifier(keys, cfg.Auth.JWTAudience, cfg.Auth.JWTIssuer)
+	}
 
-	docs.SwaggerInfo.Host = os.Getenv("HOST")
+	auditLog, err := os.OpenFile(cfg.Auth.AuditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Fatal("error al intentar abrir el log de auditoría: ", err)
+	}
+	auth := handler.NewAuth(keyService, cfg.Auth.Token, verifier, audit.NewLogger(auditLog))
+
+	r := gin.New()
+	// El log y las métricas van primero para registrar el código que termina escribiendo ErrorHandler
+	r.Use(logging.Middleware(logger), tracing.Middleware(), m.Middleware(), gin.Recovery(), web.ErrorHandler(), web.Timeout(cfg.Server.RequestTimeout.Duration))
+
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("products", func(ctx context.Context) error {
+		var p []domain.Product
+		return db.Read(ctx, &p)
+	})
+	checker.Add("apikeys", func(ctx context.Context) error {
+		var k []domain.APIKey
+		// Sin archivo de claves no hay claves emitidas, no es una falla
+		if err := keysDB.Read(ctx, &k); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+	r.GET("/healthz", checker.Liveness())
+	r.GET("/readyz", checker.Readiness())
+	r.GET("/metrics", m.Handler())
+
+	docs.SwaggerInfo.Host = cfg.Server.Host
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	pr := r.Group("/products")
+	pr := r.Group("/products", auth.Authorize(handler.ProductPolicy))
 	{
-		pr.GET("/", pc.ValidateToken, pc.GetAll())
-		pr.POST("/", pc.ValidateToken, pc.Store())
-		pr.PUT("/:id", pc.ValidateToken, pc.Update())
-		pr.DELETE("/:id", pc.ValidateToken, pc.Delete(false))
-		pr.DELETE("/hardDelete/:id", pc.ValidateToken, pc.Delete(true))
-		pr.PATCH("/:id", pc.ValidateToken, pc.UpdateNameAndPrice())
-	}
-	err = r.Run()
-	if err != nil {
-		log.Fatal("error al intentar correr el server")
+		pr.GET("/", pc.GetAll())
+		pr.GET("/trash", pc.Trash())
+		pr.GET("/export", pc.Export())
+		pr.GET("/by-code/:code", pc.GetByCode())
+		pr.GET("/:id", pc.Get())
+		pr.POST("/", pc.Store())
+		pr.POST("/bulk", pc.Bulk())
+		pr.POST("/import", pc.Import())
+		pr.PUT("/:id", pc.Update())
+		pr.DELETE("/:id", pc.Delete(false))
+		pr.DELETE("/hardDelete/:id", pc.Delete(true))
+		pr.PATCH("/:id", pc.Patch())
+		pr.POST("/:id/restore", pc.Restore())
+	}
+
+	kr := r.Group("/apikeys", auth.Authorize(handler.APIKeyPolicy))
+	{
+		kr.GET("/", kc.List())
+		kr.POST("/", kc.Issue())
+		kr.DELETE("/:id", kc.Revoke())
+	}
+
+	srv := server.New(r, server.Config{
+		Addr:              cfg.Server.Addr,
+		ReadTimeout:       cfg.Server.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.Server.WriteTimeout.Duration,
+		IdleTimeout:       cfg.Server.IdleTimeout.Duration,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ShutdownTimeout:   cfg.Server.ShutdownTimeout.Duration,
+		DrainDelay:        cfg.Server.DrainDelay.Duration,
+	})
+	srv.OnDraining(checker.ShuttingDown)
+
+	// Los hooks corren en orden inverso: primero se detiene el purgador, después se cierran los stores, después se exportan los
+	// spans pendientes y al final se vacía el log de auditoría
+	srv.OnShutdown(func(ctx context.Context) error {
+		if err := auditLog.Sync(); err != nil {
+			return err
+		}
+		return auditLog.Close()
+	})
+	srv.OnShutdown(shutdownTracing)
+	srv.OnShutdown(keysDB.Close)
+	srv.OnShutdown(db.Close)
+
+	// El purgador se detiene antes de cerrar los stores
+	if retention := cfg.Store.TrashRetention.Duration; retention > 0 {
+		purgeCtx, cancelPurge := context.WithCancel(logger.WithContext(context.Background()))
+		purged := make(chan struct{})
+		go func() {
+			defer close(purged)
+			products.PurgeTrashEvery(purgeCtx, service, retention, cfg.Store.TrashPurgeInterval.Duration)
+		}()
+		srv.OnShutdown(func(ctx context.Context) error {
+			cancelPurge()
+			select {
+			case <-purged:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("escuchando en %s", cfg.Server.Addr)
+	if err := srv.Run(ctx); err != nil {
+		log.Fatal("error al intentar correr el server: ", err)
 	}
+	log.Print("servidor detenido")
 }
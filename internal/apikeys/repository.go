@@ -0,0 +1,80 @@
+package apikeys
+
+import (
+	"context"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/store"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context) ([]domain.APIKey, error)
+	Store(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	Revoke(ctx context.Context, id string, at time.Time) (domain.APIKey, error)
+}
+
+type repository struct {
+	db store.Store
+}
+
+func NewRepository(db store.Store) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+
+	if err := r.db.Read(ctx, &keys); err != nil {
+		return []domain.APIKey{}, apperrors.Unavailable(err)
+	}
+	return keys, nil
+}
+
+func (r *repository) Store(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	var keys []domain.APIKey
+
+	unlock, err := r.db.Lock(ctx)
+	if err != nil {
+		return domain.APIKey{}, apperrors.Unavailable(err)
+	}
+	defer unlock()
+
+	if err := r.db.Read(ctx, &keys); err != nil && !isMissing(err) {
+		return domain.APIKey{}, apperrors.Unavailable(err)
+	}
+
+	keys = append(keys, key)
+	if err := r.db.Write(ctx, keys); err != nil {
+		return domain.APIKey{}, apperrors.Unavailable(err)
+	}
+	return key, nil
+}
+
+func (r *repository) Revoke(ctx context.Context, id string, at time.Time) (domain.APIKey, error) {
+	var keys []domain.APIKey
+
+	unlock, err := r.db.Lock(ctx)
+	if err != nil {
+		return domain.APIKey{}, apperrors.Unavailable(err)
+	}
+	defer unlock()
+
+	if err := r.db.Read(ctx, &keys); err != nil && !isMissing(err) {
+		return domain.APIKey{}, apperrors.Unavailable(err)
+	}
+
+	for i := range keys {
+		if keys[i].Id == id {
+			if keys[i].RevokedAt == nil {
+				keys[i].RevokedAt = &at
+			}
+			if err := r.db.Write(ctx, keys); err != nil {
+				return domain.APIKey{}, apperrors.Unavailable(err)
+			}
+			return keys[i], nil
+		}
+	}
+	return domain.APIKey{}, apperrors.NotFound("api_key_not_found", id)
+}
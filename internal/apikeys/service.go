@@ -0,0 +1,127 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/validation"
+)
+
+// keyPrefix permite reconocer a simple vista (y con escáneres de secretos) una clave de esta API.
+const keyPrefix = "gw_"
+
+type Service interface {
+	// Issue emite una clave nueva y devuelve su valor en claro, que no vuelve a estar disponible.
+	Issue(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (string, domain.APIKey, error)
+	Revoke(ctx context.Context, id string) (domain.APIKey, error)
+	List(ctx context.Context) ([]domain.APIKey, error)
+	// Authenticate devuelve la clave que corresponde a key si existe, no expiró y no fue revocada.
+	Authenticate(ctx context.Context, key string) (domain.APIKey, error)
+}
+
+type service struct {
+	repository Repository
+	now        func() time.Time
+}
+
+func NewService(r Repository) Service {
+	return &service{repository: r, now: time.Now}
+}
+
+func (s *service) Issue(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (string, domain.APIKey, error) {
+	secret, err := randomString(32)
+	if err != nil {
+		return "", domain.APIKey{}, err
+	}
+	id, err := randomString(9)
+	if err != nil {
+		return "", domain.APIKey{}, err
+	}
+	plain := keyPrefix + secret
+
+	key := domain.APIKey{
+		Id:        id,
+		Name:      name,
+		Hash:      hash(plain),
+		Prefix:    plain[:len(keyPrefix)+6],
+		Scopes:    scopes,
+		CreatedAt: s.now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if err := validation.Struct(key); err != nil {
+		return "", domain.APIKey{}, err
+	}
+	if expiresAt != nil && !expiresAt.After(key.CreatedAt) {
+		err := apperrors.Validation("validation_failed")
+		err.Fields = []apperrors.FieldError{{Field: "expiresAt", Code: "future"}}
+		return "", domain.APIKey{}, err
+	}
+
+	key, err = s.repository.Store(ctx, key)
+	if err != nil {
+		return "", domain.APIKey{}, err
+	}
+	return plain, key, nil
+}
+
+func (s *service) Revoke(ctx context.Context, id string) (domain.APIKey, error) {
+	return s.repository.Revoke(ctx, id, s.now().UTC())
+}
+
+func (s *service) List(ctx context.Context) ([]domain.APIKey, error) {
+	keys, err := s.repository.GetAll(ctx)
+	if isMissing(err) {
+		return []domain.APIKey{}, nil
+	}
+	return keys, err
+}
+
+func (s *service) Authenticate(ctx context.Context, key string) (domain.APIKey, error) {
+	if key == "" {
+		return domain.APIKey{}, apperrors.Unauthorized("unauthorized")
+	}
+
+	keys, err := s.List(ctx)
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	hashed := hash(key)
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashed)) == 1 {
+			if !k.Usable(s.now()) {
+				break
+			}
+			return k, nil
+		}
+	}
+	return domain.APIKey{}, apperrors.Unauthorized("unauthorized")
+}
+
+// Las claves tienen 256 bits aleatorios, así que un SHA-256 sin sal alcanza para no guardarlas en claro.
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// isMissing indica que el archivo de claves todavía no existe: equivale a no tener claves.
+func isMissing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,87 @@
+package apikeys
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServiceTest(t *testing.T) *service {
+	db := store.New(store.FileType, filepath.Join(t.TempDir(), "apikeys.json"))
+	return NewService(NewRepository(db)).(*service)
+}
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	s := newServiceTest(t)
+
+	plain, key, err := s.Issue(context.Background(), "catalogo", []string{domain.ScopeProductsRead}, nil)
+	assert.Nil(t, err, "no debería dar error")
+	assert.True(t, strings.HasPrefix(plain, keyPrefix), "la clave debe tener el prefijo")
+	assert.NotContains(t, key.Hash, plain, "no se debe guardar la clave en claro")
+	assert.Equal(t, plain[:len(key.Prefix)], key.Prefix, "deben ser iguales")
+
+	found, err := s.Authenticate(context.Background(), plain)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, key.Id, found.Id, "deben ser iguales")
+	assert.True(t, found.HasScope(domain.ScopeProductsRead))
+	assert.False(t, found.HasScope(domain.ScopeProductsWrite))
+}
+
+func TestAuthenticate_Unknown(t *testing.T) {
+	s := newServiceTest(t)
+
+	_, err := s.Authenticate(context.Background(), "gw_inexistente")
+	assert.Equal(t, apperrors.KindUnauthorized, apperrors.KindOf(err), "deben ser iguales")
+
+	_, err = s.Authenticate(context.Background(), "")
+	assert.Equal(t, apperrors.KindUnauthorized, apperrors.KindOf(err), "deben ser iguales")
+}
+
+func TestAuthenticate_Revoked(t *testing.T) {
+	s := newServiceTest(t)
+	plain, key, err := s.Issue(context.Background(), "catalogo", []string{domain.ScopeProductsRead}, nil)
+	assert.Nil(t, err, "no debería dar error")
+
+	revoked, err := s.Revoke(context.Background(), key.Id)
+	assert.Nil(t, err, "no debería dar error")
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = s.Authenticate(context.Background(), plain)
+	assert.Equal(t, apperrors.KindUnauthorized, apperrors.KindOf(err), "deben ser iguales")
+}
+
+func TestAuthenticate_Expired(t *testing.T) {
+	s := newServiceTest(t)
+	expiresAt := time.Now().Add(time.Hour)
+	plain, _, err := s.Issue(context.Background(), "catalogo", []string{domain.ScopeProductsRead}, &expiresAt)
+	assert.Nil(t, err, "no debería dar error")
+
+	s.now = func() time.Time { return expiresAt.Add(time.Second) }
+	_, err = s.Authenticate(context.Background(), plain)
+	assert.Equal(t, apperrors.KindUnauthorized, apperrors.KindOf(err), "deben ser iguales")
+}
+
+func TestIssue_Invalid(t *testing.T) {
+	s := newServiceTest(t)
+	past := time.Now().Add(-time.Hour)
+
+	_, _, err := s.Issue(context.Background(), "catalogo", []string{"products:all"}, nil)
+	assert.Equal(t, apperrors.KindValidation, apperrors.KindOf(err), "deben ser iguales")
+
+	_, _, err = s.Issue(context.Background(), "catalogo", []string{domain.ScopeProductsRead}, &past)
+	assert.Equal(t, []apperrors.FieldError{{Field: "expiresAt", Code: "future"}}, apperrors.FieldsOf(err), "deben ser iguales")
+}
+
+func TestRevoke_NotFound(t *testing.T) {
+	s := newServiceTest(t)
+
+	_, err := s.Revoke(context.Background(), "inexistente")
+	assert.True(t, apperrors.IsNotFound(err))
+}
@@ -0,0 +1,275 @@
+// Package config arma la configuración del servidor combinando, de menor a mayor prioridad,
+// los valores por defecto, un archivo YAML o JSON opcional, las variables de entorno y los
+// flags de la línea de comandos, y la valida antes de arrancar.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server  ServerConfig  `json:"server" yaml:"server"`
+	Store   StoreConfig   `json:"store" yaml:"store"`
+	Auth    AuthConfig    `json:"auth" yaml:"auth"`
+	Log     LogConfig     `json:"log" yaml:"log"`
+	Tracing TracingConfig `json:"tracing" yaml:"tracing"`
+	// Locale es el idioma de los mensajes cuando el cliente no envía Accept-Language.
+	Locale string `json:"locale" yaml:"locale"`
+}
+
+type ServerConfig struct {
+	Addr string `json:"addr" yaml:"addr"`
+	// Host es el host que se publica en la documentación de Swagger.
+	Host              string   `json:"host" yaml:"host"`
+	ReadTimeout       Duration `json:"readTimeout" yaml:"readTimeout"`
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout" yaml:"readHeaderTimeout"`
+	WriteTimeout      Duration `json:"writeTimeout" yaml:"writeTimeout"`
+	IdleTimeout       Duration `json:"idleTimeout" yaml:"idleTimeout"`
+	MaxHeaderBytes    int      `json:"maxHeaderBytes" yaml:"maxHeaderBytes"`
+	ShutdownTimeout   Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"`
+	// DrainDelay es cuánto se sigue atendiendo, con readiness fallando, antes de cerrar el listener.
+	DrainDelay Duration `json:"drainDelay" yaml:"drainDelay"`
+	// RequestTimeout limita el trabajo de cada petición; 0 no impone límite.
+	RequestTimeout Duration `json:"requestTimeout" yaml:"requestTimeout"`
+}
+
+type StoreConfig struct {
+	Type        string `json:"type" yaml:"type"`
+	File        string `json:"file" yaml:"file"`
+	APIKeysFile string `json:"apiKeysFile" yaml:"apiKeysFile"`
+	// TrashRetention es cuánto queda un producto en la papelera antes de purgarse; 0 no purga.
+	TrashRetention     Duration `json:"trashRetention" yaml:"trashRetention"`
+	TrashPurgeInterval Duration `json:"trashPurgeInterval" yaml:"trashPurgeInterval"`
+	// UniqueCodeScope es all si el código de un producto no se puede repetir nunca, o active si
+	// se puede reutilizar el de un producto dado de baja.
+	UniqueCodeScope string `json:"uniqueCodeScope" yaml:"uniqueCodeScope"`
+}
+
+type AuthConfig struct {
+	// Token es el TOKEN único anterior a las API keys; vacío lo deshabilita.
+	Token       string   `json:"token" yaml:"token"`
+	JWTKeyFiles []string `json:"jwtKeyFiles" yaml:"jwtKeyFiles"`
+	JWTAudience string   `json:"jwtAudience" yaml:"jwtAudience"`
+	JWTIssuer   string   `json:"jwtIssuer" yaml:"jwtIssuer"`
+	AuditFile   string   `json:"auditFile" yaml:"auditFile"`
+}
+
+type LogConfig struct {
+	Level string `json:"level" yaml:"level"`
+}
+
+type TracingConfig struct {
+	// Exporter es none, stdout, otlp-file u otlp-http.
+	Exporter string `json:"exporter" yaml:"exporter"`
+	// Target es el archivo (stdout, otlp-file) o el endpoint host:puerto (otlp-http).
+	Target      string  `json:"target" yaml:"target"`
+	SampleRatio float64 `json:"sampleRatio" yaml:"sampleRatio"`
+	ServiceName string  `json:"serviceName" yaml:"serviceName"`
+}
+
+// Log levels válidos.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// Alcances de la unicidad del código que define products.CodeScope.
+var codeScopes = []string{"all", "active"}
+
+// Exporters de tracing que registra pkg/tracing.
+var tracingExporters = []string{"none", "stdout", "otlp-file", "otlp-http"}
+
+// Default devuelve la configuración que se usa si no se indica otra cosa.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       Duration{15 * time.Second},
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			WriteTimeout:      Duration{30 * time.Second},
+			IdleTimeout:       Duration{60 * time.Second},
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   Duration{20 * time.Second},
+			RequestTimeout:    Duration{10 * time.Second},
+		},
+		Store: StoreConfig{
+			Type:               "file",
+			File:               "./products.json",
+			APIKeysFile:        "./apikeys.json",
+			TrashPurgeInterval: Duration{time.Hour},
+			UniqueCodeScope:    "all",
+		},
+		Auth: AuthConfig{
+			AuditFile: "./audit.log",
+		},
+		Log: LogConfig{Level: "info"},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "products-api",
+		},
+		Locale: "es",
+	}
+}
+
+// Load arma la configuración a partir de args (sin el nombre del programa) y de getenv. El
+// archivo se indica con el flag -config o la variable CONFIG_FILE.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs, flagValues, configFile := newFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *configFile == "" {
+		*configFile = getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		if v := getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("variable %s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Los flags se aplican al final, en el orden en que se pasaron
+	for _, fv := range *flagValues {
+		if err := fv.setting.set(&cfg, fv.value); err != nil {
+			return Config{}, fmt.Errorf("flag -%s: %w", fv.setting.flag, err)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	default:
+		return fmt.Errorf("%s: el archivo de configuración debe ser .json, .yaml o .yml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate devuelve todos los problemas de la configuración juntos.
+func (c Config) Validate() error {
+	var problems ValidationError
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("server.addr %q no es una dirección host:puerto válida", c.Server.Addr))
+	}
+	for name, d := range map[string]Duration{
+		"server.readTimeout":       c.Server.ReadTimeout,
+		"server.readHeaderTimeout": c.Server.ReadHeaderTimeout,
+		"server.writeTimeout":      c.Server.WriteTimeout,
+		"server.idleTimeout":       c.Server.IdleTimeout,
+		"server.requestTimeout":    c.Server.RequestTimeout,
+		"server.drainDelay":        c.Server.DrainDelay,
+		"store.trashRetention":     c.Store.TrashRetention,
+		"store.trashPurgeInterval": c.Store.TrashPurgeInterval,
+	} {
+		if d.Duration < 0 {
+			problems = append(problems, name+" no puede ser negativo")
+		}
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		problems = append(problems, "server.shutdownTimeout debe ser mayor a cero")
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		problems = append(problems, "server.maxHeaderBytes debe ser mayor a cero")
+	}
+	if c.Server.WriteTimeout.Duration > 0 && c.Server.RequestTimeout.Duration > c.Server.WriteTimeout.Duration {
+		problems = append(problems, "server.requestTimeout no puede superar a server.writeTimeout")
+	}
+
+	if c.Store.Type != "file" && c.Store.Type != "sqlite" {
+		problems = append(problems, fmt.Sprintf("store.type %q no es file ni sqlite", c.Store.Type))
+	}
+	if c.Store.File == "" {
+		problems = append(problems, "store.file es obligatorio")
+	}
+	if c.Store.APIKeysFile == "" {
+		problems = append(problems, "store.apiKeysFile es obligatorio")
+	}
+	if c.Store.TrashRetention.Duration > 0 && c.Store.TrashPurgeInterval.Duration <= 0 {
+		problems = append(problems, "store.trashPurgeInterval debe ser mayor a cero si hay retención")
+	}
+	if !contains(codeScopes, c.Store.UniqueCodeScope) {
+		problems = append(problems, fmt.Sprintf("store.uniqueCodeScope %q no es uno de %s", c.Store.UniqueCodeScope, strings.Join(codeScopes, ", ")))
+	}
+
+	if c.Auth.AuditFile == "" {
+		problems = append(problems, "auth.auditFile es obligatorio")
+	}
+	if len(c.Auth.JWTKeyFiles) == 0 && (c.Auth.JWTAudience != "" || c.Auth.JWTIssuer != "") {
+		problems = append(problems, "auth.jwtAudience y auth.jwtIssuer requieren auth.jwtKeyFiles")
+	}
+
+	if !contains(logLevels, c.Log.Level) {
+		problems = append(problems, fmt.Sprintf("log.level %q no es uno de %s", c.Log.Level, strings.Join(logLevels, ", ")))
+	}
+	if c.Locale == "" {
+		problems = append(problems, "locale es obligatorio")
+	}
+
+	if !contains(tracingExporters, c.Tracing.Exporter) {
+		problems = append(problems, fmt.Sprintf("tracing.exporter %q no es uno de %s", c.Tracing.Exporter, strings.Join(tracingExporters, ", ")))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "tracing.sampleRatio debe estar entre 0 y 1")
+	}
+	if c.Tracing.Exporter != "none" && c.Tracing.ServiceName == "" {
+		problems = append(problems, "tracing.serviceName es obligatorio")
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// ValidationError enumera los problemas encontrados al validar la configuración.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "configuración inválida: " + strings.Join(e, "; ")
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, Default(), cfg, "deben ser iguales")
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+  requestTimeout: 5s
+store:
+  type: sqlite
+  file: ./products.db
+log:
+  level: warn
+`)
+	cfg, err := Load(
+		[]string{"-config", file, "-log-level", "debug"},
+		env(map[string]string{"PORT": "9100", "STORE_FILE": "/data/products.db", "JWT_KEY_FILES": "a.pem, b.json", "LOG_LEVEL": "error"}),
+	)
+
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, ":9100", cfg.Server.Addr, "el entorno pisa al archivo")
+	assert.Equal(t, 5*time.Second, cfg.Server.RequestTimeout.Duration, "el archivo pisa al default")
+	assert.Equal(t, "sqlite", cfg.Store.Type, "deben ser iguales")
+	assert.Equal(t, "/data/products.db", cfg.Store.File, "deben ser iguales")
+	assert.Equal(t, []string{"a.pem", "b.json"}, cfg.Auth.JWTKeyFiles, "deben ser iguales")
+	assert.Equal(t, "debug", cfg.Log.Level, "el flag pisa al entorno")
+	assert.Equal(t, Default().Server.WriteTimeout, cfg.Server.WriteTimeout, "lo no configurado conserva el default")
+}
+
+func TestLoad_JSONFileFromEnv(t *testing.T) {
+	file := writeFile(t, "config.json", `{"store": {"file": "./otro.json"}, "server": {"shutdownTimeout": "1m"}}`)
+
+	cfg, err := Load(nil, env(map[string]string{"CONFIG_FILE": file}))
+
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, "./otro.json", cfg.Store.File, "deben ser iguales")
+	assert.Equal(t, time.Minute, cfg.Server.ShutdownTimeout.Duration, "deben ser iguales")
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := Load([]string{"-store-type", "mongo", "-log-level", "verbose", "-addr", "8080", "-tracing-exporter", "jaeger"}, env(nil))
+
+	var problems ValidationError
+	assert.ErrorAs(t, err, &problems, "debería ser un error de validación")
+	assert.Len(t, problems, 4, "debe reportar todos los problemas juntos")
+
+	_, err = Load([]string{"-trash-retention", "720h", "-trash-purge-interval", "0s"}, env(nil))
+	assert.ErrorAs(t, err, &problems, "la purga necesita un intervalo")
+
+	_, err = Load(nil, env(map[string]string{"UNIQUE_CODE_SCOPE": "published"}))
+	assert.ErrorAs(t, err, &problems, "el alcance del código debería ser all o active")
+
+	_, err = Load(nil, env(map[string]string{"REQUEST_TIMEOUT": "diez"}))
+	assert.NotNil(t, err, "debería dar error")
+
+	_, err = Load([]string{"-config", writeFile(t, "config.yaml", "store:\n  tipo: file\n")}, env(nil))
+	assert.NotNil(t, err, "los campos desconocidos deberían dar error")
+
+	_, err = Load([]string{"-config", writeFile(t, "config.toml", "")}, env(nil))
+	assert.NotNil(t, err, "debería dar error")
+}
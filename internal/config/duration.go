@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+// Duration es un time.Duration que en los archivos de configuración se escribe como texto
+// con el formato de time.ParseDuration, por ejemplo "30s" o "1m30s".
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting es un valor configurable por variable de entorno y/o flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+// settings es la tabla de todo lo que se puede configurar fuera del archivo. Se conservan los
+// nombres de las variables que el servidor leía antes de existir este paquete.
+var settings = []setting{
+	// PORT es el nombre que usa gin; ADDR, que va después, tiene prioridad
+	{"PORT", "", "", func(c *Config, v string) error {
+		c.Server.Addr = ":" + v
+		return nil
+	}},
+	{"ADDR", "addr", "dirección host:puerto donde escucha el servidor", func(c *Config, v string) error {
+		c.Server.Addr = v
+		return nil
+	}},
+	{"HOST", "host", "host publicado en la documentación de Swagger", func(c *Config, v string) error {
+		c.Server.Host = v
+		return nil
+	}},
+	{"SERVER_READ_TIMEOUT", "read-timeout", "tiempo máximo para leer una petición completa", durationSetter(func(c *Config) *Duration { return &c.Server.ReadTimeout })},
+	{"SERVER_READ_HEADER_TIMEOUT", "read-header-timeout", "tiempo máximo para leer los headers", durationSetter(func(c *Config) *Duration { return &c.Server.ReadHeaderTimeout })},
+	{"SERVER_WRITE_TIMEOUT", "write-timeout", "tiempo máximo para escribir la respuesta", durationSetter(func(c *Config) *Duration { return &c.Server.WriteTimeout })},
+	{"SERVER_IDLE_TIMEOUT", "idle-timeout", "tiempo máximo de una conexión keep-alive inactiva", durationSetter(func(c *Config) *Duration { return &c.Server.IdleTimeout })},
+	{"SERVER_MAX_HEADER_BYTES", "max-header-bytes", "tamaño máximo de los headers", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		c.Server.MaxHeaderBytes = n
+		return err
+	}},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "período de gracia del apagado", durationSetter(func(c *Config) *Duration { return &c.Server.ShutdownTimeout })},
+	{"SHUTDOWN_DRAIN_DELAY", "drain-delay", "tiempo que se sigue atendiendo con readiness fallando antes de cerrar el listener", durationSetter(func(c *Config) *Duration { return &c.Server.DrainDelay })},
+	{"REQUEST_TIMEOUT", "request-timeout", "tiempo máximo de trabajo por petición (0 = sin límite)", durationSetter(func(c *Config) *Duration { return &c.Server.RequestTimeout })},
+	{"STORE_TYPE", "store-type", "tipo de almacenamiento: file o sqlite", func(c *Config, v string) error {
+		c.Store.Type = v
+		return nil
+	}},
+	{"STORE_FILE", "store-file", "archivo de datos de productos", func(c *Config, v string) error {
+		c.Store.File = v
+		return nil
+	}},
+	{"API_KEYS_FILE", "api-keys-file", "archivo de API keys", func(c *Config, v string) error {
+		c.Store.APIKeysFile = v
+		return nil
+	}},
+	{"TRASH_RETENTION", "trash-retention", "tiempo en la papelera antes de purgar un producto (0 = sin purga)", durationSetter(func(c *Config) *Duration { return &c.Store.TrashRetention })},
+	{"TRASH_PURGE_INTERVAL", "trash-purge-interval", "cada cuánto se purga la papelera", durationSetter(func(c *Config) *Duration { return &c.Store.TrashPurgeInterval })},
+	{"UNIQUE_CODE_SCOPE", "unique-code-scope", "productos entre los que el código es único: all o active", func(c *Config, v string) error {
+		c.Store.UniqueCodeScope = strings.ToLower(v)
+		return nil
+	}},
+	{"TOKEN", "", "", func(c *Config, v string) error {
+		c.Auth.Token = v
+		return nil
+	}},
+	{"JWT_KEY_FILES", "jwt-key-files", "archivos PEM, JWKS o secretos HS256 separados por coma", func(c *Config, v string) error {
+		c.Auth.JWTKeyFiles = nil
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				c.Auth.JWTKeyFiles = append(c.Auth.JWTKeyFiles, f)
+			}
+		}
+		return nil
+	}},
+	{"JWT_AUDIENCE", "jwt-audience", "aud que deben tener los JWT", func(c *Config, v string) error {
+		c.Auth.JWTAudience = v
+		return nil
+	}},
+	{"JWT_ISSUER", "jwt-issuer", "iss que deben tener los JWT", func(c *Config, v string) error {
+		c.Auth.JWTIssuer = v
+		return nil
+	}},
+	{"AUDIT_FILE", "audit-file", "archivo del log de auditoría", func(c *Config, v string) error {
+		c.Auth.AuditFile = v
+		return nil
+	}},
+	{"LOG_LEVEL", "log-level", "nivel de log: debug, info, warn o error", func(c *Config, v string) error {
+		c.Log.Level = strings.ToLower(v)
+		return nil
+	}},
+	{"TRACING_EXPORTER", "tracing-exporter", "exporter de trazas: none, stdout, otlp-file u otlp-http", func(c *Config, v string) error {
+		c.Tracing.Exporter = strings.ToLower(v)
+		return nil
+	}},
+	{"TRACING_TARGET", "tracing-target", "archivo o endpoint host:puerto al que se exportan las trazas", func(c *Config, v string) error {
+		c.Tracing.Target = v
+		return nil
+	}},
+	{"TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "fracción de trazas nuevas que se registran, entre 0 y 1", func(c *Config, v string) error {
+		r, err := strconv.ParseFloat(v, 64)
+		c.Tracing.SampleRatio = r
+		return err
+	}},
+	{"TRACING_SERVICE_NAME", "tracing-service-name", "nombre del servicio en las trazas", func(c *Config, v string) error {
+		c.Tracing.ServiceName = v
+		return nil
+	}},
+	{"DEFAULT_LOCALE", "locale", "idioma por defecto de los mensajes", func(c *Config, v string) error {
+		c.Locale = v
+		return nil
+	}},
+}
+
+type flagValue struct {
+	setting setting
+	value   string
+}
+
+// newFlagSet define un flag por cada setting que lo admite; los valores se guardan para
+// aplicarlos después del archivo y del entorno. El token nunca se acepta por flag, porque
+// quedaría visible en la lista de procesos.
+func newFlagSet() (*flag.FlagSet, *[]flagValue, *string) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	values := &[]flagValue{}
+	configFile := fs.String("config", "", "archivo de configuración YAML o JSON")
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		fs.Func(s.flag, s.usage, func(v string) error {
+			*values = append(*values, flagValue{setting: s, value: v})
+			return nil
+		})
+	}
+	return fs, values, configFile
+}
+
+func durationSetter(field func(c *Config) *Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		field(c).Duration = d
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// Scopes conocidos. Cada ruta de la API exige uno de ellos a la credencial que la invoca.
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeProductsPurge = "products:purge"
+	ScopeKeysAdmin     = "keys:admin"
+	// ScopeProductsReadInactive permite listar la papelera y, junto con products:read, consultar
+	// los productos dados de baja en el resto de las rutas.
+	ScopeProductsReadInactive = "products:read-inactive"
+)
+
+// APIKey es una credencial emitida para un cliente. Solo se persiste el hash de la clave;
+// el valor en claro se muestra una única vez al emitirla.
+type APIKey struct {
+	Id        string     `json:"id"`
+	Name      string     `json:"name" validate:"required,max=100"`
+	Hash      string     `json:"hash"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=products:read products:read-inactive products:write products:purge keys:admin"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable indica si la clave no fue revocada ni expiró al momento now.
+func (k APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
@@ -1,13 +1,34 @@
 package domain
 
+import (
+	"time"
+
+	"github.com/palomavs/go-web-II/pkg/validation"
+)
+
+// CodePattern es el formato del código de producto: letras mayúsculas y dígitos. El servicio pasa
+// el código a mayúsculas antes de validarlo.
+const CodePattern = `^[A-Z0-9]+$`
+
+func init() {
+	validation.RegisterPattern("productCode", CodePattern)
+}
+
+// Las reglas de `validate` se evalúan en el servicio antes de persistir (ver pkg/validation).
 type Product struct {
 	Id           int     `json:"id"`
-	Name         string  `json:"name"`
-	Color        string  `json:"color"`
-	Price        float64 `json:"price"`
-	Stock        int     `json:"stock"`
-	Code         string  `json:"code"`
+	Name         string  `json:"name" validate:"required,max=100"`
+	Color        string  `json:"color" validate:"required,max=50"`
+	Price        float64 `json:"price" validate:"min=0"`
+	Stock        int     `json:"stock" validate:"min=0"`
+	Code         string  `json:"code" validate:"required,max=20,pattern=productCode"`
 	Published    bool    `json:"published"`
-	CreationDate string  `json:"creationDate"`
+	CreationDate string  `json:"creationDate" validate:"required,date=2-1-2006"`
 	Active       bool    `json:"active"`
+	// Version aumenta con cada modificación; es el ETag del producto.
+	Version int `json:"version"`
+	// DeletedAt y DeletedBy registran quién dio de baja el producto y cuándo; se limpian al
+	// restaurarlo. Los productos dados de baja antes de existir estos campos no los tienen.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
 }
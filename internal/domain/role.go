@@ -0,0 +1,11 @@
+package domain
+
+// Roles que el SSO asigna en el claim roles del JWT.
+const (
+	// RoleViewer solo puede consultar productos.
+	RoleViewer = "viewer"
+	// RoleEditor además puede crear, modificar y dar de baja productos.
+	RoleEditor = "editor"
+	// RoleAdmin además puede borrarlos definitivamente y administrar las API keys.
+	RoleAdmin = "admin"
+)
@@ -0,0 +1,82 @@
+package products
+
+import (
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+// Operaciones que acepta Bulk.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// BulkOperation es una operación de un lote. Update y delete identifican el producto con
+// Product.Id y respetan Version como los métodos individuales; create ignora ambos. Delete es
+// una baja (ver Delete); DeletedAt y DeletedBy, que también usa el update que desactiva un
+// producto, los completa el servicio.
+type BulkOperation struct {
+	Op        string
+	Product   domain.Product
+	Version   int
+	DeletedAt time.Time
+	DeletedBy string
+}
+
+type BulkOptions struct {
+	// Atomic descarta el lote completo si falla alguna operación.
+	Atomic bool
+	// ContinueOnError sigue con las operaciones restantes después de una falla en lugar de
+	// detenerse. En modo atómico sirve para informar todas las fallas de una vez.
+	ContinueOnError bool
+}
+
+// BulkResult es el resultado de la operación de la misma posición. Una operación sin Err que no
+// quedó Applied no llegó a ejecutarse o se descartó junto con el lote.
+type BulkResult struct {
+	Product domain.Product
+	Err     error
+	Applied bool
+}
+
+// runBulk ejecuta ops en orden con apply. Los errores de almacenamiento cortan el lote y se
+// devuelven; los de cada operación quedan en su resultado. Devuelve si alguna operación falló.
+func runBulk(ops []BulkOperation, opts BulkOptions, apply func(op BulkOperation) (domain.Product, error)) ([]BulkResult, bool, error) {
+	results := make([]BulkResult, len(ops))
+	failed := false
+	for i, op := range ops {
+		if failed && !opts.ContinueOnError {
+			break
+		}
+		product, err := apply(op)
+		if apperrors.KindOf(err) == apperrors.KindUnavailable {
+			return nil, true, err
+		}
+		if err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+		results[i] = BulkResult{Product: product, Applied: true}
+	}
+
+	if failed && opts.Atomic {
+		for i := range results {
+			results[i].Applied = false
+		}
+	}
+	return results, failed, nil
+}
+
+func countCreates(ops []BulkOperation) int {
+	n := 0
+	for _, op := range ops {
+		if op.Op == OpCreate {
+			n++
+		}
+	}
+	return n
+}
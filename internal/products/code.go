@@ -0,0 +1,110 @@
+package products
+
+import (
+	"strings"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+// CodeScope indica entre qué productos Code tiene que ser único. Los códigos se comparan sin
+// distinguir mayúsculas.
+type CodeScope string
+
+const (
+	CodeScopeAll CodeScope = "all"
+	// CodeScopeActive permite reutilizar el código de un producto dado de baja.
+	CodeScopeActive CodeScope = "active"
+)
+
+// CodeScopes son los valores válidos de CodeScope.
+var CodeScopes = []CodeScope{CodeScopeAll, CodeScopeActive}
+
+// indexes indica si p ocupa su código.
+func (s CodeScope) indexes(p domain.Product) bool {
+	return s != CodeScopeActive || p.Active
+}
+
+// codeIndex asocia cada código ocupado a los ids de los productos que lo usan. Normalmente hay
+// uno solo; puede haber más en datos guardados antes de que se exigiera la unicidad.
+type codeIndex struct {
+	scope CodeScope
+	ids   map[string][]int
+}
+
+func newCodeIndex(scope CodeScope, products []domain.Product) codeIndex {
+	index := codeIndex{scope: scope, ids: map[string][]int{}}
+	for _, p := range products {
+		index.add(p)
+	}
+	return index
+}
+
+// check devuelve un error Conflict si guardar p lo haría compartir el código con otro producto.
+func (c codeIndex) check(p domain.Product) error {
+	if !c.scope.indexes(p) {
+		return nil
+	}
+	for _, id := range c.ids[codeKey(p.Code)] {
+		if id != p.Id {
+			return errDuplicateCode(p.Code)
+		}
+	}
+	return nil
+}
+
+// replace actualiza el índice cuando old pasa a ser p; old es nil para un producto nuevo.
+func (c codeIndex) replace(old *domain.Product, p domain.Product) {
+	if old != nil {
+		c.remove(*old)
+	}
+	c.add(p)
+}
+
+func (c codeIndex) add(p domain.Product) {
+	if c.scope.indexes(p) {
+		key := codeKey(p.Code)
+		c.ids[key] = append(c.ids[key], p.Id)
+	}
+}
+
+func (c codeIndex) remove(p domain.Product) {
+	key := codeKey(p.Code)
+	ids := c.ids[key]
+	for i, id := range ids {
+		if id == p.Id {
+			c.ids[key] = append(ids[:i:i], ids[i+1:]...)
+			return
+		}
+	}
+}
+
+// pickByCode elige entre products, que comparten el código code, el que lo representa: el activo
+// o, si están todos dados de baja, el último creado. Devuelve nil si products está vacío. Más de
+// un activo solo puede venir de datos guardados antes de exigir la unicidad: es un error Conflict.
+func pickByCode(code string, products []domain.Product) (*domain.Product, error) {
+	var found *domain.Product
+	for i, p := range products {
+		switch {
+		case p.Active && found != nil && found.Active:
+			return nil, apperrors.Conflict("ambiguous_code", code)
+		case p.Active || found == nil || !found.Active:
+			found = &products[i]
+		}
+	}
+	return found, nil
+}
+
+// normalizeCode lleva code a mayúsculas, la forma en que se guardan los códigos; el servicio lo
+// aplica antes de validar, así que "ab12" se acepta y se guarda como "AB12".
+func normalizeCode(code string) string {
+	return strings.ToUpper(code)
+}
+
+func codeKey(code string) string {
+	return strings.ToUpper(code)
+}
+
+func errDuplicateCode(code string) error {
+	return apperrors.Conflict("duplicate_code", code)
+}
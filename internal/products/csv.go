@@ -0,0 +1,268 @@
+package products
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+// CSVColumns son las columnas que escribe WriteCSV y que ReadCSV reconoce, con el nombre JSON
+// del campo de domain.Product.
+var CSVColumns = []string{"code", "name", "color", "price", "stock", "published", "creationDate", "active"}
+
+// WriteCSV escribe products con una fila de encabezado con CSVColumns.
+func WriteCSV(w io.Writer, products []domain.Product) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(CSVColumns); err != nil {
+		return err
+	}
+	for _, p := range products {
+		record := []string{
+			escapeFormula(p.Code),
+			escapeFormula(p.Name),
+			escapeFormula(p.Color),
+			strconv.FormatFloat(p.Price, 'f', -1, 64),
+			strconv.Itoa(p.Stock),
+			strconv.FormatBool(p.Published),
+			escapeFormula(p.CreationDate),
+			strconv.FormatBool(p.Active),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeFormula antepone un apóstrofo a los textos que una planilla interpretaría como fórmula;
+// ReadCSV lo quita al importar.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+const formulaPrefixes = "=+-@\t\r"
+
+// ImportRow es una fila de un CSV. Product solo tiene cargadas las columnas del archivo; Err
+// indica los valores que no se pudieron convertir.
+type ImportRow struct {
+	Line    int
+	Product domain.Product
+	Err     error
+}
+
+// CSVImport es un CSV leído por ReadCSV.
+type CSVImport struct {
+	// Columns son los campos presentes en el archivo, en orden.
+	Columns []string
+	// Ignored son los encabezados que no corresponden a ningún campo.
+	Ignored []string
+	Rows    []ImportRow
+}
+
+func (c CSVImport) has(column string) bool {
+	for _, present := range c.Columns {
+		if present == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadCSV lee un CSV con encabezado. Cada encabezado se asocia al campo que indique mapping o,
+// si no figura ahí, al campo de CSVColumns del mismo nombre, sin distinguir mayúsculas, espacios
+// ni guiones ("Creation Date" es creationDate). La columna code es obligatoria.
+func ReadCSV(r io.Reader, mapping map[string]string) (CSVImport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	// Las filas cortas se completan con vacíos y se validan como cualquier otra
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return CSVImport{}, apperrors.Validation("missing_column", "code")
+	}
+	if err != nil {
+		return CSVImport{}, apperrors.Validation("invalid_csv", err.Error())
+	}
+
+	fields := map[string]string{}
+	for _, column := range CSVColumns {
+		fields[normalizeColumn(column)] = column
+	}
+	for from, to := range mapping {
+		field, ok := fields[normalizeColumn(to)]
+		if !ok {
+			return CSVImport{}, apperrors.Validation("invalid_mapping", from, to)
+		}
+		fields[normalizeColumn(from)] = field
+	}
+
+	var result CSVImport
+	columns := make([]string, len(header))
+	for i, name := range header {
+		field, ok := fields[normalizeColumn(name)]
+		switch {
+		case !ok:
+			result.Ignored = append(result.Ignored, name)
+		case result.has(field):
+			return CSVImport{}, apperrors.Validation("duplicate_column", field)
+		default:
+			columns[i] = field
+			result.Columns = append(result.Columns, field)
+		}
+	}
+	if !result.has("code") {
+		return CSVImport{}, apperrors.Validation("missing_column", "code")
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return CSVImport{}, apperrors.Validation("invalid_csv", err.Error())
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{Line: line}
+		var invalid []apperrors.FieldError
+		for i, field := range columns {
+			if field == "" {
+				continue
+			}
+			value := ""
+			if i < len(record) {
+				value = strings.TrimSpace(record[i])
+			}
+			if code := setField(&row.Product, field, value); code != "" {
+				invalid = append(invalid, apperrors.FieldError{Field: columns[i], Code: code})
+			}
+		}
+		if len(invalid) > 0 {
+			appErr := apperrors.Validation("validation_failed")
+			appErr.Fields = invalid
+			row.Err = appErr
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, nil
+}
+
+// setField carga value en el campo field de p; si el valor no corresponde al tipo del campo
+// devuelve el código de la falla.
+func setField(p *domain.Product, field, value string) string {
+	var err error
+	switch field {
+	case "code":
+		p.Code = unescapeFormula(value)
+	case "name":
+		p.Name = unescapeFormula(value)
+	case "color":
+		p.Color = unescapeFormula(value)
+	case "price":
+		if p.Price, err = strconv.ParseFloat(value, 64); err != nil || math.IsNaN(p.Price) || math.IsInf(p.Price, 0) {
+			return "number"
+		}
+	case "stock":
+		if p.Stock, err = strconv.Atoi(value); err != nil {
+			return "integer"
+		}
+	case "published":
+		if p.Published, err = strconv.ParseBool(value); err != nil {
+			return "boolean"
+		}
+	case "creationDate":
+		p.CreationDate = unescapeFormula(value)
+	case "active":
+		if p.Active, err = strconv.ParseBool(value); err != nil {
+			return "boolean"
+		}
+	}
+	return ""
+}
+
+// Acciones de ImportResult.
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+)
+
+// ImportResult es lo que pasó, o pasaría en un dry run, con una fila del CSV.
+type ImportResult struct {
+	Line    int
+	Action  string
+	Product domain.Product
+	Err     error
+}
+
+type ImportReport struct {
+	Results                             []ImportResult
+	Created, Updated, Unchanged, Failed int
+	// Applied indica si los cambios se guardaron: nunca en un dry run ni si falló alguna fila.
+	Applied bool
+}
+
+func (r *ImportReport) count() {
+	r.Created, r.Updated, r.Unchanged, r.Failed = 0, 0, 0, 0
+	for _, result := range r.Results {
+		switch {
+		case result.Err != nil:
+			r.Failed++
+		case result.Action == ImportCreate:
+			r.Created++
+		case result.Action == ImportUpdate:
+			r.Updated++
+		case result.Action == ImportUnchanged:
+			r.Unchanged++
+		}
+	}
+}
+
+// copyColumns copia en dst los campos columns de src.
+func copyColumns(dst *domain.Product, src domain.Product, columns []string) {
+	for _, column := range columns {
+		switch column {
+		case "code":
+			dst.Code = src.Code
+		case "name":
+			dst.Name = src.Name
+		case "color":
+			dst.Color = src.Color
+		case "price":
+			dst.Price = src.Price
+		case "stock":
+			dst.Stock = src.Stock
+		case "published":
+			dst.Published = src.Published
+		case "creationDate":
+			dst.CreationDate = src.CreationDate
+		case "active":
+			dst.Active = src.Active
+		}
+	}
+}
+
+func normalizeColumn(name string) string {
+	// Excel antepone un BOM al primer encabezado
+	name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name))
+}
@@ -0,0 +1,131 @@
+package products
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	input := []domain.Product{
+		{Code: "KJS4", Name: "Mate, de calabaza", Color: "celeste", Price: 44.44, Stock: 222, Published: true, CreationDate: "13-12-2021", Active: true},
+		{Code: "7UF4", Name: "=HYPERLINK(\"x\")", Color: "azul", Price: 14.14, Stock: 672, CreationDate: "13-12-2021"},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteCSV(&buf, input), "no debería dar error")
+	assert.Contains(t, buf.String(), `'=HYPERLINK`, "las fórmulas deben escaparse")
+
+	file, err := ReadCSV(&buf, nil)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, CSVColumns, file.Columns, "deben ser iguales")
+	assert.Equal(t, []ImportRow{{Line: 2, Product: input[0]}, {Line: 3, Product: input[1]}}, file.Rows, "deben ser iguales")
+}
+
+func TestReadCSV_Mapping(t *testing.T) {
+	data := "\ufeffSKU,Creation Date,Precio,notas\nKJS4,13-12-2021,barato,x\n7UF4\n"
+
+	file, err := ReadCSV(strings.NewReader(data), map[string]string{"sku": "code", "Precio": "price"})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []string{"code", "creationDate", "price"}, file.Columns, "deben ser iguales")
+	assert.Equal(t, []string{"notas"}, file.Ignored, "deben ser iguales")
+	assert.Equal(t, []apperrors.FieldError{{Field: "price", Code: "number"}}, apperrors.FieldsOf(file.Rows[0].Err), "el mensaje se traduce al responder")
+	assert.Equal(t, 3, file.Rows[1].Line, "deben ser iguales")
+
+	_, err = ReadCSV(strings.NewReader("name,color\n"), nil)
+	assert.Equal(t, apperrors.Validation("missing_column", "code"), err, "deben ser iguales")
+
+	_, err = ReadCSV(strings.NewReader("code\n"), map[string]string{"sku": "precio"})
+	assert.Equal(t, apperrors.KindValidation, apperrors.KindOf(err), "deben ser iguales")
+}
+
+func TestServiceImport(t *testing.T) {
+	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true, Version: 1}
+	input := []domain.Product{prod1, prod2}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	service := NewService(repository)
+
+	data := "code,name,color,price,creationDate\nKJS4,prod1,celeste,44.44,13-12-2021\n7UF4,prod2,azul,20,13-12-2021\nNEW1,nuevo,rojo,5,13-12-2021\n"
+	file, err := ReadCSV(strings.NewReader(data), nil)
+	assert.Nil(t, err, "no debería dar error")
+
+	report, err := service.Import(context.Background(), file, "key-1", true)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []string{ImportUnchanged, ImportUpdate, ImportCreate}, []string{report.Results[0].Action, report.Results[1].Action, report.Results[2].Action}, "deben ser iguales")
+	assert.False(t, report.Applied, "un dry run no guarda")
+	all, _ := repository.GetAll(context.Background())
+	assert.Equal(t, input, all, "deben ser iguales")
+
+	// Una fila inválida impide guardar las demás
+	invalid, _ := ReadCSV(strings.NewReader(data+"NEW1,repetido,rojo,5,13-12-2021\nbad,x,,1,1\n"), nil)
+	report, err = service.Import(context.Background(), invalid, "key-1", false)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, 2, report.Failed, "deben ser iguales")
+	assert.Equal(t, apperrors.Validation("duplicate_row", "NEW1", 4), report.Results[3].Err, "deben ser iguales")
+	assert.False(t, report.Applied, "no debería guardar")
+
+	report, err = service.Import(context.Background(), file, "key-1", false)
+	assert.Nil(t, err, "no debería dar error")
+	assert.True(t, report.Applied, "debería guardar")
+	assert.Equal(t, 1, report.Created, "deben ser iguales")
+	assert.Equal(t, 1, report.Updated, "deben ser iguales")
+	assert.Equal(t, 1, report.Unchanged, "deben ser iguales")
+
+	all, _ = repository.GetAll(context.Background())
+	updated := prod2
+	updated.Price = 20
+	updated.Version = 2
+	assert.Equal(t, updated, all[1], "solo cambian las columnas del archivo")
+	assert.Equal(t, "NEW1", all[2].Code, "deben ser iguales")
+	assert.True(t, all[2].Active, "los productos nuevos empiezan activos")
+}
+
+func TestServiceImport_ReusedCode(t *testing.T) {
+	deleted := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: false, Version: 2}
+	active := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "KJS4", Published: false, CreationDate: "13-12-2021", Active: true, Version: 1}
+	legacy1 := domain.Product{Id: 3, Name: "prod3", Color: "rojo", Price: 10, Stock: 1, Code: "ZZ12", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	legacy2 := legacy1
+	legacy2.Id = 4
+	input := []domain.Product{deleted, active, legacy1, legacy2}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	service := NewService(NewRepository(&storeMock, CodeScopeActive))
+
+	// El código reutilizado corresponde al producto activo
+	file, err := ReadCSV(strings.NewReader("code,price\nKJS4,20\nZZ12,5\n"), nil)
+	assert.Nil(t, err, "no debería dar error")
+	report, err := service.Import(context.Background(), file, "key-1", true)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Nil(t, report.Results[0].Err, "no debería dar error")
+	assert.Equal(t, ImportUpdate, report.Results[0].Action, "deben ser iguales")
+	assert.Equal(t, active.Id, report.Results[0].Product.Id, "deben ser iguales")
+	assert.Equal(t, apperrors.Conflict("ambiguous_code", "ZZ12"), report.Results[1].Err, "dos productos activos con el mismo código son ambiguos")
+}
@@ -0,0 +1,108 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+// StoreObserver registra la duración y el resultado de una operación del almacenamiento; lo
+// implementa *metrics.Metrics.
+type StoreObserver interface {
+	ObserveStore(store, operation string, d time.Duration, err error)
+}
+
+type instrumentedRepository struct {
+	next     Repository
+	name     string
+	observer StoreObserver
+}
+
+// NewInstrumentedRepository devuelve un Repository que mide cada método de r como una operación
+// del store name. Es para los repositorios que no pasan por un store.Store instrumentado, como el
+// SQL. Como en el store, solo las fallas del almacenamiento cuentan como error: un producto
+// inexistente o una versión vencida son respuestas válidas.
+func NewInstrumentedRepository(r Repository, name string, observer StoreObserver) Repository {
+	return &instrumentedRepository{next: r, name: name, observer: observer}
+}
+
+func (r *instrumentedRepository) observe(operation string, start time.Time, err error) {
+	if apperrors.KindOf(err) != apperrors.KindUnavailable {
+		err = nil
+	}
+	r.observer.ObserveStore(r.name, operation, time.Since(start), err)
+}
+
+func (r *instrumentedRepository) GetAll(ctx context.Context) (products []domain.Product, err error) {
+	defer func(start time.Time) { r.observe("get_all", start, err) }(time.Now())
+
+	return r.next.GetAll(ctx)
+}
+
+func (r *instrumentedRepository) Count(ctx context.Context) (active, inactive int, err error) {
+	defer func(start time.Time) { r.observe("count", start, err) }(time.Now())
+
+	return r.next.Count(ctx)
+}
+
+func (r *instrumentedRepository) GetByID(ctx context.Context, id int) (product domain.Product, err error) {
+	defer func(start time.Time) { r.observe("get_by_id", start, err) }(time.Now())
+
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *instrumentedRepository) GetByCode(ctx context.Context, code string) (products []domain.Product, err error) {
+	defer func(start time.Time) { r.observe("get_by_code", start, err) }(time.Now())
+
+	return r.next.GetByCode(ctx, code)
+}
+
+func (r *instrumentedRepository) Store(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (product domain.Product, err error) {
+	defer func(start time.Time) { r.observe("store", start, err) }(time.Now())
+
+	return r.next.Store(ctx, id, name, color, price, stock, code, published, creationDate, active)
+}
+
+func (r *instrumentedRepository) LastID(ctx context.Context) (id int, err error) {
+	defer func(start time.Time) { r.observe("last_id", start, err) }(time.Now())
+
+	return r.next.LastID(ctx)
+}
+
+func (r *instrumentedRepository) NextID(ctx context.Context) (id int, err error) {
+	defer func(start time.Time) { r.observe("next_id", start, err) }(time.Now())
+
+	return r.next.NextID(ctx)
+}
+
+func (r *instrumentedRepository) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedAt time.Time, deletedBy string, version int) (product domain.Product, err error) {
+	defer func(start time.Time) { r.observe("update", start, err) }(time.Now())
+
+	return r.next.Update(ctx, id, name, color, price, stock, code, published, creationDate, active, deletedAt, deletedBy, version)
+}
+
+func (r *instrumentedRepository) HardDelete(ctx context.Context, id int, version int) (products []domain.Product, err error) {
+	defer func(start time.Time) { r.observe("hard_delete", start, err) }(time.Now())
+
+	return r.next.HardDelete(ctx, id, version)
+}
+
+func (r *instrumentedRepository) Delete(ctx context.Context, id int, deletedAt time.Time, deletedBy string, version int) (products []domain.Product, err error) {
+	defer func(start time.Time) { r.observe("delete", start, err) }(time.Now())
+
+	return r.next.Delete(ctx, id, deletedAt, deletedBy, version)
+}
+
+func (r *instrumentedRepository) Restore(ctx context.Context, id int, version int) (product domain.Product, err error) {
+	defer func(start time.Time) { r.observe("restore", start, err) }(time.Now())
+
+	return r.next.Restore(ctx, id, version)
+}
+
+func (r *instrumentedRepository) Bulk(ctx context.Context, ops []BulkOperation, opts BulkOptions) (results []BulkResult, err error) {
+	defer func(start time.Time) { r.observe("bulk", start, err) }(time.Now())
+
+	return r.next.Bulk(ctx, ops, opts)
+}
@@ -0,0 +1,330 @@
+package products
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+// DateLayout es el formato de CreationDate (día-mes-año, sin ceros a la izquierda obligatorios).
+const DateLayout = "2-1-2006"
+
+// DefaultCursorLimit se usa cuando se pagina por cursor sin indicar limit.
+const DefaultCursorLimit = 20
+
+// Query describe los filtros, el orden y la página a devolver por Service.Search.
+// Los punteros en nil significan "sin filtro".
+type Query struct {
+	Name        string
+	Colors      []string
+	PriceMin    *float64
+	PriceMax    *float64
+	StockBelow  *int
+	Published   *bool
+	Active      *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+
+	Sort []SortKey
+
+	// Limit en 0 devuelve todos los resultados desde Offset. Cursor y Offset son excluyentes.
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Page es el resultado de una búsqueda: la porción pedida más lo necesario para navegar.
+type Page struct {
+	Products   []domain.Product
+	Total      int
+	Offset     int
+	Limit      int
+	HasNext    bool
+	HasPrev    bool
+	NextCursor string
+	PrevCursor string
+}
+
+type comparator func(a, b domain.Product) int
+
+var sortFields = map[string]comparator{
+	"id": func(a, b domain.Product) int { return compareInt(a.Id, b.Id) },
+	"name": func(a, b domain.Product) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"color": func(a, b domain.Product) int {
+		return strings.Compare(strings.ToLower(a.Color), strings.ToLower(b.Color))
+	},
+	"price":        func(a, b domain.Product) int { return compareFloat(a.Price, b.Price) },
+	"stock":        func(a, b domain.Product) int { return compareInt(a.Stock, b.Stock) },
+	"code":         func(a, b domain.Product) int { return strings.Compare(a.Code, b.Code) },
+	"published":    func(a, b domain.Product) int { return compareBool(a.Published, b.Published) },
+	"creationDate": compareCreationDate,
+	"active":       func(a, b domain.Product) int { return compareBool(a.Active, b.Active) },
+}
+
+// ParseSort interpreta una lista separada por comas de campos, con "-" como prefijo para orden descendente
+// (por ejemplo "-price,name").
+func ParseSort(value string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key := SortKey{Field: field}
+		if strings.HasPrefix(field, "-") {
+			key = SortKey{Field: field[1:], Desc: true}
+		}
+		if _, ok := sortFields[key.Field]; !ok {
+			return nil, apperrors.Validation("invalid_sort_field", key.Field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ParseDate interpreta una fecha en el formato de CreationDate.
+func ParseDate(value string) (time.Time, error) {
+	return time.Parse(DateLayout, value)
+}
+
+func (q Query) matches(p domain.Product) bool {
+	if q.Name != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(q.Name)) {
+		return false
+	}
+	if len(q.Colors) > 0 {
+		found := false
+		for _, c := range q.Colors {
+			if strings.EqualFold(c, p.Color) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.PriceMin != nil && p.Price < *q.PriceMin {
+		return false
+	}
+	if q.PriceMax != nil && p.Price > *q.PriceMax {
+		return false
+	}
+	if q.StockBelow != nil && p.Stock >= *q.StockBelow {
+		return false
+	}
+	if q.Published != nil && p.Published != *q.Published {
+		return false
+	}
+	if q.Active != nil && p.Active != *q.Active {
+		return false
+	}
+	if q.CreatedFrom != nil || q.CreatedTo != nil {
+		created, err := ParseDate(p.CreationDate)
+		if err != nil {
+			return false
+		}
+		if q.CreatedFrom != nil && created.Before(*q.CreatedFrom) {
+			return false
+		}
+		if q.CreatedTo != nil && created.After(*q.CreatedTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// compare ordena según las claves pedidas y desempata por id para que el orden sea total,
+// requisito para que los cursores sean estables.
+func (q Query) compare(a, b domain.Product) int {
+	for _, key := range q.Sort {
+		c := sortFields[key.Field](a, b)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInt(a.Id, b.Id)
+}
+
+// sortValues da acceso al campo de cada clave de orden, para guardarlo en el cursor y
+// recuperarlo al decodificarlo.
+var sortValues = map[string]func(p *domain.Product) interface{}{
+	"id":           func(p *domain.Product) interface{} { return &p.Id },
+	"name":         func(p *domain.Product) interface{} { return &p.Name },
+	"color":        func(p *domain.Product) interface{} { return &p.Color },
+	"price":        func(p *domain.Product) interface{} { return &p.Price },
+	"stock":        func(p *domain.Product) interface{} { return &p.Stock },
+	"code":         func(p *domain.Product) interface{} { return &p.Code },
+	"published":    func(p *domain.Product) interface{} { return &p.Published },
+	"creationDate": func(p *domain.Product) interface{} { return &p.CreationDate },
+	"active":       func(p *domain.Product) interface{} { return &p.Active },
+}
+
+// cursor guarda solo los valores de las claves de orden de la consulta, en el mismo orden, y el
+// id que desempata; no expone el resto del producto.
+type cursor struct {
+	Before bool              `json:"b,omitempty"`
+	Values []json.RawMessage `json:"v,omitempty"`
+	Id     int               `json:"id"`
+}
+
+func (q Query) encodeCursor(p domain.Product, before bool) string {
+	c := cursor{Before: before, Id: p.Id}
+	for _, key := range q.Sort {
+		raw, _ := json.Marshal(sortValues[key.Field](&p))
+		c.Values = append(c.Values, raw)
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor devuelve si el cursor apunta hacia atrás y un producto con solo los campos de
+// orden y el id, suficiente para compararlo con compare.
+func (q Query) decodeCursor(value string) (bool, domain.Product, error) {
+	var c cursor
+	var key domain.Product
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return false, key, apperrors.Validation("invalid_cursor")
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || len(c.Values) != len(q.Sort) {
+		return false, key, apperrors.Validation("invalid_cursor")
+	}
+	for i, k := range q.Sort {
+		if err := json.Unmarshal(c.Values[i], sortValues[k.Field](&key)); err != nil {
+			return false, key, apperrors.Validation("invalid_cursor")
+		}
+	}
+	key.Id = c.Id
+	return c.Before, key, nil
+}
+
+// apply filtra, ordena y pagina products según la consulta.
+func (q Query) apply(products []domain.Product) (Page, error) {
+	if q.Cursor != "" && q.Offset > 0 {
+		return Page{}, apperrors.Validation("cursor_with_offset")
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return Page{}, apperrors.Validation("negative_pagination")
+	}
+
+	filtered := []domain.Product{}
+	for _, p := range products {
+		if q.matches(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return q.compare(filtered[i], filtered[j]) < 0
+	})
+
+	total := len(filtered)
+	start, end := q.Offset, total
+	limit := q.Limit
+
+	if q.Cursor != "" {
+		before, key, err := q.decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		if limit == 0 {
+			limit = DefaultCursorLimit
+		}
+
+		if before {
+			end = sort.Search(total, func(i int) bool { return q.compare(filtered[i], key) >= 0 })
+			start = end - limit
+			if start < 0 {
+				start = 0
+			}
+		} else {
+			start = sort.Search(total, func(i int) bool { return q.compare(filtered[i], key) > 0 })
+		}
+	}
+
+	if start > total {
+		start = total
+	}
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	page := Page{
+		Products: filtered[start:end],
+		Total:    total,
+		Offset:   start,
+		Limit:    limit,
+		HasPrev:  start > 0,
+		HasNext:  end < total,
+	}
+	if page.HasNext && end > start {
+		page.NextCursor = q.encodeCursor(filtered[end-1], false)
+	}
+	if page.HasPrev && start < total {
+		page.PrevCursor = q.encodeCursor(filtered[start], true)
+	}
+	return page, nil
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
+
+// compareCreationDate compara cronológicamente; las fechas que no respetan DateLayout van al final.
+func compareCreationDate(a, b domain.Product) int {
+	da, errA := ParseDate(a.CreationDate)
+	db, errB := ParseDate(b.CreationDate)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a.CreationDate, b.CreationDate)
+	case errA != nil:
+		return 1
+	case errB != nil:
+		return -1
+	case da.Before(db):
+		return -1
+	case da.After(db):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package products
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func searchServiceTest(t *testing.T) (Service, []domain.Product) {
+	input := []domain.Product{
+		{Id: 1, Name: "Mate", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true},
+		{Id: 2, Name: "Bombilla", Color: "azul", Price: 14.14, Stock: 5, Code: "7UF4", Published: false, CreationDate: "1-1-2020", Active: true},
+		{Id: 3, Name: "Termo", Color: "verde", Price: 99.9, Stock: 10, Code: "TR3", Published: true, CreationDate: "3-5-2005", Active: false},
+		{Id: 4, Name: "Mate de vidrio", Color: "Azul", Price: 44.44, Stock: 1, Code: "MV1", Published: true, CreationDate: "20-6-2021", Active: true},
+	}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	return NewService(NewRepository(&storeMock, CodeScopeAll)), input
+}
+
+func TestServiceSearchFilters(t *testing.T) {
+	service, input := searchServiceTest(t)
+	active := true
+	priceMin := 40.0
+	stockBelow := 100
+	from, _ := ParseDate("1-1-2021")
+
+	page, err := service.Search(context.Background(), Query{Name: "mate", Active: &active})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[0], input[3]}, page.Products, "deben ser iguales")
+
+	page, err = service.Search(context.Background(), Query{Colors: []string{"azul", "verde"}})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[1], input[2], input[3]}, page.Products, "deben ser iguales")
+
+	page, err = service.Search(context.Background(), Query{PriceMin: &priceMin, StockBelow: &stockBelow, CreatedFrom: &from})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[3]}, page.Products, "deben ser iguales")
+	assert.Equal(t, 1, page.Total, "deben ser iguales")
+}
+
+func TestServiceSearchSort(t *testing.T) {
+	service, input := searchServiceTest(t)
+
+	keys, err := ParseSort("-price,creationDate")
+	assert.Nil(t, err, "no debería dar error")
+
+	page, err := service.Search(context.Background(), Query{Sort: keys})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[2], input[3], input[0], input[1]}, page.Products, "deben ser iguales")
+
+	_, err = ParseSort("weight")
+	assert.NotNil(t, err, "debería dar error")
+}
+
+func TestServiceSearchOffsetAndCursor(t *testing.T) {
+	service, input := searchServiceTest(t)
+
+	page, err := service.Search(context.Background(), Query{Limit: 2, Offset: 1})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[1], input[2]}, page.Products, "deben ser iguales")
+	assert.Equal(t, 4, page.Total, "deben ser iguales")
+	assert.True(t, page.HasNext)
+	assert.True(t, page.HasPrev)
+
+	page, err = service.Search(context.Background(), Query{Limit: 2, Cursor: page.NextCursor})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[3]}, page.Products, "deben ser iguales")
+	assert.False(t, page.HasNext)
+
+	page, err = service.Search(context.Background(), Query{Limit: 2, Cursor: page.PrevCursor})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[1], input[2]}, page.Products, "deben ser iguales")
+
+	_, err = service.Search(context.Background(), Query{Cursor: "no-es-un-cursor"})
+	assert.NotNil(t, err, "debería dar error")
+}
+
+func TestServiceSearchCursorSortKeys(t *testing.T) {
+	service, input := searchServiceTest(t)
+	keys, _ := ParseSort("-price")
+
+	page, err := service.Search(context.Background(), Query{Sort: keys, Limit: 2})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[2], input[0]}, page.Products, "deben ser iguales")
+
+	raw, err := base64.RawURLEncoding.DecodeString(page.NextCursor)
+	assert.Nil(t, err, "no debería dar error")
+	assert.JSONEq(t, `{"v":[44.44],"id":1}`, string(raw), "deben ser iguales")
+
+	page, err = service.Search(context.Background(), Query{Sort: keys, Limit: 2, Cursor: page.NextCursor})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, []domain.Product{input[3], input[1]}, page.Products, "deben ser iguales")
+
+	_, err = service.Search(context.Background(), Query{Limit: 2, Cursor: page.PrevCursor})
+	assert.NotNil(t, err, "un cursor de otro orden debería dar error")
+}
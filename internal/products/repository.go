@@ -2,63 +2,140 @@ package products
 
 import (
 	"context"
-	"fmt"
+	"time"
 
 	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
 	"github.com/palomavs/go-web-II/pkg/store"
 )
 
 type Repository interface {
 	GetAll(ctx context.Context) ([]domain.Product, error)
+	// Count devuelve cuántos productos hay activos y cuántos dados de baja, sin cargarlos.
+	Count(ctx context.Context) (active, inactive int, err error)
+	GetByID(ctx context.Context, id int) (domain.Product, error)
+	// GetByCode devuelve los productos con ese código, sin distinguir mayúsculas, ordenados por
+	// id. Puede haber más de uno si el alcance de la unicidad es solo los productos activos.
+	GetByCode(ctx context.Context, code string) ([]domain.Product, error)
+	// Store y Update devuelven un error Conflict si el código ya lo usa otro producto.
 	Store(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error)
 	LastID(ctx context.Context) (int, error)
-	Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error)
-	UpdateNameAndPrice(ctx context.Context, id int, name string, price float64) (domain.Product, error)
-	HardDelete(ctx context.Context, id int) ([]domain.Product, error)
-	Delete(ctx context.Context, id int) ([]domain.Product, error)
+	NextID(ctx context.Context) (int, error)
+	// Update, HardDelete, Delete y Restore solo modifican el producto si su versión
+	// actual es version (o si version es AnyVersion); si no, devuelven un error PreconditionFailed.
+	// Cada modificación incrementa la versión.
+	// Si Update deja inactivo a un producto sin baja registrada, la registra con deletedAt y
+	// deletedBy como Delete.
+	Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedAt time.Time, deletedBy string, version int) (domain.Product, error)
+	HardDelete(ctx context.Context, id int, version int) ([]domain.Product, error)
+	// Delete da de baja el producto; si ya estaba dado de baja conserva el momento y el responsable
+	// originales.
+	Delete(ctx context.Context, id int, deletedAt time.Time, deletedBy string, version int) ([]domain.Product, error)
+	// Restore reactiva un producto dado de baja y limpia DeletedAt y DeletedBy. Si el producto
+	// está activo o si otro producto tomó su código devuelve un error Conflict.
+	Restore(ctx context.Context, id int, version int) (domain.Product, error)
+	// Bulk aplica ops en orden y las guarda juntas; cada operación se comporta como el método
+	// individual correspondiente. Solo devuelve error si falla el almacenamiento. Los ids de los
+	// create se reservan antes de aplicar el lote y no se devuelven: un lote rechazado o un create
+	// que falla dejan huecos en la numeración, como NextID seguido de un Store que falla.
+	Bulk(ctx context.Context, ops []BulkOperation, opts BulkOptions) ([]BulkResult, error)
 }
 
 type repository struct {
-	db store.Store
+	db        store.Store
+	codeScope CodeScope
 }
 
-func NewRepository(db store.Store) Repository {
-	return &repository{db: db}
+// NewRepository devuelve un Repository que exige códigos únicos entre los productos de codeScope.
+func NewRepository(db store.Store, codeScope CodeScope) Repository {
+	return &repository{db: db, codeScope: codeScope}
 }
 
 func (r *repository) GetAll(ctx context.Context) ([]domain.Product, error) {
-	var products []domain.Product
-
-	if err := r.db.Read(&products); err != nil {
+	products, err := r.read(ctx)
+	if err != nil {
 		return []domain.Product{}, err
 	}
 	return products, nil
 }
 
+// Count decodifica solo el campo active de cada producto.
+func (r *repository) Count(ctx context.Context) (int, int, error) {
+	var states []struct {
+		Active bool `json:"active"`
+	}
+	if err := r.db.Read(ctx, &states); err != nil {
+		return 0, 0, apperrors.Unavailable(err)
+	}
+
+	active := 0
+	for _, s := range states {
+		if s.Active {
+			active++
+		}
+	}
+	return active, len(states) - active, nil
+}
+
+func (r *repository) GetByID(ctx context.Context, id int) (domain.Product, error) {
+	products, err := r.read(ctx)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	for _, p := range products {
+		if p.Id == id {
+			return p, nil
+		}
+	}
+	return domain.Product{}, errNotFound(id)
+}
+
+func (r *repository) GetByCode(ctx context.Context, code string) ([]domain.Product, error) {
+	products, err := r.read(ctx)
+	if err != nil {
+		return []domain.Product{}, err
+	}
+
+	matches := []domain.Product{}
+	for _, p := range products {
+		if codeKey(p.Code) == codeKey(code) {
+			matches = append(matches, p)
+		}
+	}
+	return matches, nil
+}
+
 func (r *repository) Store(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error) {
-	newProduct := domain.Product{Id: id, Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active}
-	var products []domain.Product
+	newProduct := domain.Product{Id: id, Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active, Version: 1}
+
+	unlock, err := r.db.Lock(ctx)
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+	defer unlock()
 
 	//Lo vamos a sobreescribir completo, así que necesitamos leerlo antes
-	err := r.db.Read(&products)
+	products, err := r.read(ctx)
 	if err != nil {
 		return domain.Product{}, err
 	}
+	if err := newCodeIndex(r.codeScope, products).check(newProduct); err != nil {
+		return domain.Product{}, err
+	}
 
 	//Lo escribimos
 	products = append(products, newProduct)
-	err = r.db.Write(products)
+	err = r.db.Write(ctx, products)
 	if err != nil {
-		return domain.Product{}, err
+		return domain.Product{}, apperrors.Unavailable(err)
 	}
 
 	return newProduct, nil
 }
 
 func (r *repository) LastID(ctx context.Context) (int, error) {
-	var products []domain.Product
-
-	err := r.db.Read(&products)
+	products, err := r.read(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -70,127 +147,257 @@ func (r *repository) LastID(ctx context.Context) (int, error) {
 	return products[len(products)-1].Id, nil
 }
 
-func (r *repository) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error) {
-	updatedProduct := domain.Product{Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active}
-	var products []domain.Product
-	found := false
+// NextID reserva un id nuevo. El contador vive en el store, así que un id nunca se reutiliza
+// aunque se haga HardDelete del último producto.
+func (r *repository) NextID(ctx context.Context) (int, error) {
+	return r.reserveIDs(ctx, 1)
+}
 
-	//Lo vamos a sobreescribir completo, así que necesitamos leerlo antes
-	err := r.db.Read(&products)
+// reserveIDs reserva n ids consecutivos y devuelve el primero.
+func (r *repository) reserveIDs(ctx context.Context, n int) (int, error) {
+	lastID, err := r.LastID(ctx)
 	if err != nil {
-		return domain.Product{}, err
+		return 0, err
 	}
 
-	for i := range products {
-		if products[i].Id == id {
-			found = true
-			updatedProduct.Id = id
-			products[i] = updatedProduct
-			break
-		}
+	firstID, err := r.db.Sequence(ctx, lastID, n)
+	if err != nil {
+		return 0, apperrors.Unavailable(err)
+	}
+	return firstID, nil
+}
+
+func (r *repository) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedAt time.Time, deletedBy string, version int) (domain.Product, error) {
+	updatedProduct := domain.Product{Id: id, Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active, DeletedAt: &deletedAt, DeletedBy: deletedBy}
+
+	unlock, err := r.db.Lock(ctx)
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
 	}
+	defer unlock()
 
-	if !found {
-		return domain.Product{}, fmt.Errorf("producto de id %d no encontrado", id)
+	//Lo vamos a sobreescribir completo, así que necesitamos leerlo antes
+	products, err := r.read(ctx)
+	if err != nil {
+		return domain.Product{}, err
 	}
 
-	err = r.db.Write(products)
+	updatedProduct, err = update(products, updatedProduct, version, newCodeIndex(r.codeScope, products))
 	if err != nil {
 		return domain.Product{}, err
 	}
 
+	err = r.db.Write(ctx, products)
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+
 	return updatedProduct, nil
 }
 
-func (r *repository) UpdateNameAndPrice(ctx context.Context, id int, name string, price float64) (domain.Product, error) {
-	found := false
-	var products []domain.Product
-	var index int
-
-	//Lo vamos a sobreescribir completo, así que necesitamos leerlo antes
-	err := r.db.Read(&products)
+func (r *repository) HardDelete(ctx context.Context, id int, version int) ([]domain.Product, error) {
+	unlock, err := r.db.Lock(ctx)
 	if err != nil {
-		return domain.Product{}, err
+		return []domain.Product{}, apperrors.Unavailable(err)
 	}
+	defer unlock()
 
-	for i := range products {
-		if products[i].Id == id {
-			found = true
-			index = i
-			products[i].Name = name
-			products[i].Price = price
-			break
-		}
+	products, err := r.read(ctx)
+	if err != nil {
+		return []domain.Product{}, err
 	}
 
-	if !found {
-		return domain.Product{}, fmt.Errorf("producto de id %d no encontrado", id)
+	index, err := find(products, id, version)
+	if err != nil {
+		return []domain.Product{}, err
 	}
 
-	err = r.db.Write(products)
+	products = append(products[:index], products[index+1:]...)
+	err = r.db.Write(ctx, products)
 	if err != nil {
-		return domain.Product{}, err
+		return []domain.Product{}, apperrors.Unavailable(err)
 	}
 
-	return products[index], nil
+	return products, nil
 }
 
-func (r *repository) HardDelete(ctx context.Context, id int) ([]domain.Product, error) {
-	found := false
-	var index int
-	var products []domain.Product
+func (r *repository) Delete(ctx context.Context, id int, deletedAt time.Time, deletedBy string, version int) ([]domain.Product, error) {
+	unlock, err := r.db.Lock(ctx)
+	if err != nil {
+		return []domain.Product{}, apperrors.Unavailable(err)
+	}
+	defer unlock()
 
-	err := r.db.Read(&products)
+	products, err := r.read(ctx)
 	if err != nil {
 		return []domain.Product{}, err
 	}
 
-	for i := range products {
-		if products[i].Id == id {
-			found = true
-			index = i
-			break
-		}
+	if _, err := softDelete(products, id, deletedAt, deletedBy, version); err != nil {
+		return []domain.Product{}, err
 	}
 
-	if !found {
-		return []domain.Product{}, fmt.Errorf("producto de id %d no encontrado", id)
+	err = r.db.Write(ctx, products)
+	if err != nil {
+		return []domain.Product{}, apperrors.Unavailable(err)
 	}
 
-	products = append(products[:index], products[index+1:]...)
-	err = r.db.Write(products)
+	return products, nil
+}
+
+func (r *repository) Restore(ctx context.Context, id int, version int) (domain.Product, error) {
+	unlock, err := r.db.Lock(ctx)
 	if err != nil {
-		return []domain.Product{}, err
+		return domain.Product{}, apperrors.Unavailable(err)
 	}
+	defer unlock()
 
-	return products, nil
+	products, err := r.read(ctx)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	i, err := find(products, id, version)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	if products[i].Active {
+		return domain.Product{}, errNotDeleted(id)
+	}
+	restored := products[i]
+	restored.Active = true
+	if err := newCodeIndex(r.codeScope, products).check(restored); err != nil {
+		return domain.Product{}, err
+	}
+	products[i].Active = true
+	products[i].DeletedAt = nil
+	products[i].DeletedBy = ""
+	products[i].Version++
+
+	err = r.db.Write(ctx, products)
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+
+	return products[i], nil
 }
 
-func (r *repository) Delete(ctx context.Context, id int) ([]domain.Product, error) {
-	found := false
-	var products []domain.Product
+// Bulk reserva los ids de todos los create de una vez y guarda el lote con una sola escritura.
+// La reserva va antes de tomar el lock porque Sequence lo toma por su cuenta.
+func (r *repository) Bulk(ctx context.Context, ops []BulkOperation, opts BulkOptions) ([]BulkResult, error) {
+	var nextID int
+	if n := countCreates(ops); n > 0 {
+		firstID, err := r.reserveIDs(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		nextID = firstID
+	}
 
-	err := r.db.Read(&products)
+	unlock, err := r.db.Lock(ctx)
 	if err != nil {
-		return []domain.Product{}, err
+		return nil, apperrors.Unavailable(err)
 	}
+	defer unlock()
 
-	for i := range products {
-		if products[i].Id == id {
-			found = true
-			products[i].Active = false
-			break
+	products, err := r.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := newCodeIndex(r.codeScope, products)
+	results, failed, err := runBulk(ops, opts, func(op BulkOperation) (domain.Product, error) {
+		switch op.Op {
+		case OpCreate:
+			p := op.Product
+			p.Id, p.Version, p.DeletedAt, p.DeletedBy = nextID, 1, nil, ""
+			nextID++
+			if err := codes.check(p); err != nil {
+				return domain.Product{}, err
+			}
+			codes.replace(nil, p)
+			products = append(products, p)
+			return p, nil
+		case OpUpdate:
+			p := op.Product
+			p.DeletedAt, p.DeletedBy = &op.DeletedAt, op.DeletedBy
+			return update(products, p, op.Version, codes)
+		case OpDelete:
+			// Con el alcance active la baja libera el código para el resto del lote
+			var old domain.Product
+			if i, err := find(products, op.Product.Id, AnyVersion); err == nil {
+				old = products[i]
+			}
+			deleted, err := softDelete(products, op.Product.Id, op.DeletedAt, op.DeletedBy, op.Version)
+			if err != nil {
+				return domain.Product{}, err
+			}
+			codes.replace(&old, deleted)
+			return deleted, nil
 		}
+		return domain.Product{}, errInvalidOperation(op.Op)
+	})
+	if err != nil || (failed && opts.Atomic) {
+		return results, err
 	}
 
-	if !found {
-		return []domain.Product{}, fmt.Errorf("producto de id %d no encontrado", id)
+	if err := r.db.Write(ctx, products); err != nil {
+		return nil, apperrors.Unavailable(err)
 	}
+	return results, nil
+}
 
-	err = r.db.Write(products)
+// update reemplaza en products al producto p.Id por p, manteniendo codes al día. La baja se
+// conserva mientras el producto siga inactivo; si no tenía, se registra con la de p.
+func update(products []domain.Product, p domain.Product, version int, codes codeIndex) (domain.Product, error) {
+	i, err := find(products, p.Id, version)
 	if err != nil {
-		return []domain.Product{}, err
+		return domain.Product{}, err
+	}
+	if err := codes.check(p); err != nil {
+		return domain.Product{}, err
+	}
+	p.Version = products[i].Version + 1
+	switch {
+	case p.Active:
+		p.DeletedAt, p.DeletedBy = nil, ""
+	case products[i].DeletedAt != nil:
+		p.DeletedAt, p.DeletedBy = products[i].DeletedAt, products[i].DeletedBy
 	}
+	codes.replace(&products[i], p)
+	products[i] = p
+	return p, nil
+}
+
+func softDelete(products []domain.Product, id int, deletedAt time.Time, deletedBy string, version int) (domain.Product, error) {
+	i, err := find(products, id, version)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	products[i].Active = false
+	products[i].Version++
+	if products[i].DeletedAt == nil {
+		products[i].DeletedAt = &deletedAt
+		products[i].DeletedBy = deletedBy
+	}
+	return products[i], nil
+}
+
+func (r *repository) read(ctx context.Context) ([]domain.Product, error) {
+	var products []domain.Product
 
+	if err := r.db.Read(ctx, &products); err != nil {
+		return nil, apperrors.Unavailable(err)
+	}
 	return products, nil
 }
+
+// find devuelve la posición del producto id, controlando que esté en la versión esperada.
+func find(products []domain.Product, id int, version int) (int, error) {
+	for i := range products {
+		if products[i].Id == id {
+			return i, checkVersion(products[i], version)
+		}
+	}
+	return 0, errNotFound(id)
+}
@@ -0,0 +1,349 @@
+package products
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/store"
+)
+
+const productColumns = "id, name, color, price, stock, code, published, creation_date, active, version, deleted_at, deleted_by"
+
+// queryer es lo que comparten *sql.DB y *sql.Tx, para que Bulk use los mismos métodos dentro de
+// una transacción.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type sqlRepository struct {
+	db        queryer
+	sqlDB     *sql.DB
+	codeScope CodeScope
+}
+
+// NewSQLRepository devuelve un Repository que opera fila por fila sobre la tabla products,
+// en lugar de leer y reescribir el catálogo completo en cada llamada. Como NewRepository, exige
+// códigos únicos entre los productos de codeScope.
+func NewSQLRepository(db *sql.DB, codeScope CodeScope) Repository {
+	return &sqlRepository{db: db, sqlDB: db, codeScope: codeScope}
+}
+
+func (r *sqlRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+productColumns+" FROM products ORDER BY id")
+	if err != nil {
+		return []domain.Product{}, apperrors.Unavailable(err)
+	}
+	defer rows.Close()
+
+	products := []domain.Product{}
+	for rows.Next() {
+		var p domain.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return []domain.Product{}, apperrors.Unavailable(err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return []domain.Product{}, apperrors.Unavailable(err)
+	}
+	return products, nil
+}
+
+func (r *sqlRepository) Count(ctx context.Context) (int, int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT active, COUNT(*) FROM products GROUP BY active")
+	if err != nil {
+		return 0, 0, apperrors.Unavailable(err)
+	}
+	defer rows.Close()
+
+	var active, inactive int
+	for rows.Next() {
+		var isActive bool
+		var n int
+		if err := rows.Scan(&isActive, &n); err != nil {
+			return 0, 0, apperrors.Unavailable(err)
+		}
+		if isActive {
+			active = n
+		} else {
+			inactive = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, apperrors.Unavailable(err)
+	}
+	return active, inactive, nil
+}
+
+func (r *sqlRepository) GetByID(ctx context.Context, id int) (domain.Product, error) {
+	var p domain.Product
+	err := scanProduct(r.db.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ?", id), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, errNotFound(id)
+	}
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+	return p, nil
+}
+
+func (r *sqlRepository) GetByCode(ctx context.Context, code string) ([]domain.Product, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+productColumns+" FROM products WHERE code = ? COLLATE NOCASE ORDER BY id", code)
+	if err != nil {
+		return []domain.Product{}, apperrors.Unavailable(err)
+	}
+	defer rows.Close()
+
+	products := []domain.Product{}
+	for rows.Next() {
+		var p domain.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return []domain.Product{}, apperrors.Unavailable(err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return []domain.Product{}, apperrors.Unavailable(err)
+	}
+	return products, nil
+}
+
+func (r *sqlRepository) Store(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error) {
+	newProduct := domain.Product{Id: id, Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active, Version: 1}
+
+	args := append([]interface{}{id, name, color, price, stock, code, published, creationDate, active, newProduct.Version}, r.codeTakenArgs(newProduct)...)
+	res, err := r.db.ExecContext(ctx, "INSERT INTO products ("+productColumns+") SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL, '' WHERE NOT "+codeTaken, args...)
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+	if affected == 0 {
+		return domain.Product{}, errDuplicateCode(code)
+	}
+
+	return newProduct, nil
+}
+
+func (r *sqlRepository) LastID(ctx context.Context) (int, error) {
+	var lastID int
+	if err := r.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM products").Scan(&lastID); err != nil {
+		return 0, apperrors.Unavailable(err)
+	}
+	return lastID, nil
+}
+
+func (r *sqlRepository) NextID(ctx context.Context) (int, error) {
+	return r.reserveIDs(ctx, 1)
+}
+
+// reserveIDs reserva n ids consecutivos y devuelve el primero.
+func (r *sqlRepository) reserveIDs(ctx context.Context, n int) (int, error) {
+	lastID, err := r.LastID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var last int
+	if err := r.db.QueryRowContext(ctx, store.SequenceQuery, "products", lastID, n).Scan(&last); err != nil {
+		return 0, apperrors.Unavailable(err)
+	}
+	return last - n + 1, nil
+}
+
+// versionMatches se agrega al WHERE de las sentencias que modifican un producto; recibe la versión
+// esperada dos veces (ver AnyVersion).
+const versionMatches = "(? < 0 OR version = ?)"
+
+// codeTaken es verdadero si el producto que se guarda ocupa su código y otro producto ya lo usa.
+// Recibe los argumentos de codeTakenArgs. El índice products_code evita recorrer la tabla.
+const codeTaken = "(? AND EXISTS (SELECT 1 FROM products other WHERE other.code = ? COLLATE NOCASE AND other.id <> ? AND (? OR other.active)))"
+
+func (r *sqlRepository) codeTakenArgs(p domain.Product) []interface{} {
+	return []interface{}{r.codeScope.indexes(p), p.Code, p.Id, r.codeScope != CodeScopeActive}
+}
+
+func (r *sqlRepository) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedAt time.Time, deletedBy string, version int) (domain.Product, error) {
+	updatedProduct := domain.Product{Id: id, Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active}
+
+	// La baja se conserva mientras el producto siga inactivo; si no tenía, se registra la recibida
+	var storedDeletedAt sql.NullTime
+	args := append([]interface{}{name, color, price, stock, code, published, creationDate, active, active, deletedAt, active, deletedBy, id, version, version}, r.codeTakenArgs(updatedProduct)...)
+	err := r.db.QueryRowContext(ctx, `UPDATE products SET name = ?, color = ?, price = ?, stock = ?, code = ?, published = ?, creation_date = ?, active = ?,
+		deleted_at = CASE WHEN ? THEN NULL ELSE COALESCE(deleted_at, ?) END, deleted_by = CASE WHEN ? THEN '' WHEN deleted_at IS NULL THEN ? ELSE deleted_by END, version = version + 1
+		WHERE id = ? AND `+versionMatches+` AND NOT `+codeTaken+` RETURNING version, deleted_at, deleted_by`, args...).Scan(&updatedProduct.Version, &storedDeletedAt, &updatedProduct.DeletedBy)
+	updatedProduct.DeletedAt = timeOrNil(storedDeletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, r.notSaved(ctx, updatedProduct)
+	}
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+
+	return updatedProduct, nil
+}
+
+func (r *sqlRepository) HardDelete(ctx context.Context, id int, version int) ([]domain.Product, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM products WHERE id = ? AND "+versionMatches, id, version, version)
+	if err != nil {
+		return []domain.Product{}, apperrors.Unavailable(err)
+	}
+	if err := r.checkAffected(ctx, res, id); err != nil {
+		return []domain.Product{}, err
+	}
+
+	return r.GetAll(ctx)
+}
+
+func (r *sqlRepository) Delete(ctx context.Context, id int, deletedAt time.Time, deletedBy string, version int) ([]domain.Product, error) {
+	if err := r.softDelete(ctx, id, deletedAt, deletedBy, version); err != nil {
+		return []domain.Product{}, err
+	}
+
+	return r.GetAll(ctx)
+}
+
+func (r *sqlRepository) softDelete(ctx context.Context, id int, deletedAt time.Time, deletedBy string, version int) error {
+	// Las expresiones del SET ven los valores anteriores de la fila, así que deleted_by se
+	// reemplaza solo si la fila no tenía deleted_at
+	res, err := r.db.ExecContext(ctx, `UPDATE products SET active = 0, deleted_by = CASE WHEN deleted_at IS NULL THEN ? ELSE deleted_by END,
+		deleted_at = COALESCE(deleted_at, ?), version = version + 1 WHERE id = ? AND `+versionMatches, deletedBy, deletedAt, id, version, version)
+	if err != nil {
+		return apperrors.Unavailable(err)
+	}
+	return r.checkAffected(ctx, res, id)
+}
+
+func (r *sqlRepository) Restore(ctx context.Context, id int, version int) (domain.Product, error) {
+	// El producto restaurado vuelve a ocupar su código, que otro pudo haber tomado mientras tanto
+	res, err := r.db.ExecContext(ctx, `UPDATE products SET active = 1, deleted_at = NULL, deleted_by = '', version = version + 1 WHERE id = ? AND active = 0 AND `+versionMatches+`
+		AND NOT EXISTS (SELECT 1 FROM products other WHERE other.code = products.code COLLATE NOCASE AND other.id <> products.id AND (? OR other.active))`,
+		id, version, version, r.codeScope != CodeScopeActive)
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return domain.Product{}, apperrors.Unavailable(err)
+	}
+	if affected == 0 {
+		current, err := r.GetByID(ctx, id)
+		if err != nil {
+			return domain.Product{}, err
+		}
+		if current.Active {
+			return domain.Product{}, errNotDeleted(id)
+		}
+		current.Active = true
+		return domain.Product{}, r.notSaved(ctx, current)
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+// Bulk corre el lote en una transacción que solo se confirma si hay algo para guardar. Una
+// sentencia que falla no deja cambios, así que sin Atomic se confirman las demás.
+func (r *sqlRepository) Bulk(ctx context.Context, ops []BulkOperation, opts BulkOptions) ([]BulkResult, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, apperrors.Unavailable(err)
+	}
+	defer tx.Rollback()
+	txRepository := &sqlRepository{db: tx, codeScope: r.codeScope}
+
+	var nextID int
+	if n := countCreates(ops); n > 0 {
+		if nextID, err = txRepository.reserveIDs(ctx, n); err != nil {
+			return nil, err
+		}
+	}
+
+	results, failed, err := runBulk(ops, opts, func(op BulkOperation) (domain.Product, error) {
+		p := op.Product
+		switch op.Op {
+		case OpCreate:
+			nextID++
+			return txRepository.Store(ctx, nextID-1, p.Name, p.Color, p.Price, p.Stock, p.Code, p.Published, p.CreationDate, p.Active)
+		case OpUpdate:
+			return txRepository.Update(ctx, p.Id, p.Name, p.Color, p.Price, p.Stock, p.Code, p.Published, p.CreationDate, p.Active, op.DeletedAt, op.DeletedBy, op.Version)
+		case OpDelete:
+			if err := txRepository.softDelete(ctx, p.Id, op.DeletedAt, op.DeletedBy, op.Version); err != nil {
+				return domain.Product{}, err
+			}
+			return txRepository.GetByID(ctx, p.Id)
+		}
+		return domain.Product{}, errInvalidOperation(op.Op)
+	})
+	if err != nil || (failed && opts.Atomic) {
+		return results, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, apperrors.Unavailable(err)
+	}
+	return results, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProduct(row rowScanner, p *domain.Product) error {
+	var deletedAt sql.NullTime
+	if err := row.Scan(&p.Id, &p.Name, &p.Color, &p.Price, &p.Stock, &p.Code, &p.Published, &p.CreationDate, &p.Active, &p.Version, &deletedAt, &p.DeletedBy); err != nil {
+		return err
+	}
+	p.DeletedAt = timeOrNil(deletedAt)
+	return nil
+}
+
+func timeOrNil(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (r *sqlRepository) checkAffected(ctx context.Context, res sql.Result, id int) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return apperrors.Unavailable(err)
+	}
+	if affected == 0 {
+		return r.notAffected(ctx, id)
+	}
+	return nil
+}
+
+// notSaved explica por qué una sentencia no guardó p: no existe, su código está ocupado o no
+// estaba en la versión esperada.
+func (r *sqlRepository) notSaved(ctx context.Context, p domain.Product) error {
+	if _, err := r.GetByID(ctx, p.Id); err != nil {
+		return err
+	}
+	var taken bool
+	if err := r.db.QueryRowContext(ctx, "SELECT "+codeTaken, r.codeTakenArgs(p)...).Scan(&taken); err != nil {
+		return apperrors.Unavailable(err)
+	}
+	if taken {
+		return errDuplicateCode(p.Code)
+	}
+	return apperrors.PreconditionFailed("version_mismatch", p.Id)
+}
+
+// notAffected explica por qué una sentencia no modificó el producto id: o no existe o no estaba
+// en la versión esperada.
+func (r *sqlRepository) notAffected(ctx context.Context, id int) error {
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return apperrors.PreconditionFailed("version_mismatch", id)
+}
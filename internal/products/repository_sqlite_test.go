@@ -0,0 +1,272 @@
+package products
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLRepositoryTest(t *testing.T, codeScope CodeScope) Repository {
+	db := store.New(store.SQLiteType, filepath.Join(t.TempDir(), "products.db")).(*store.SQLiteStore)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err, "no debería dar error")
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return NewSQLRepository(sqlDB, codeScope)
+}
+
+func TestSQLStoreAndGetAll(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: false, Version: 1}
+
+	for _, p := range []domain.Product{prod1, prod2} {
+		_, err := repository.Store(context.Background(), p.Id, p.Name, p.Color, p.Price, p.Stock, p.Code, p.Published, p.CreationDate, p.Active)
+		assert.Nil(t, err, "no debería dar error")
+	}
+
+	result, errResult := repository.GetAll(context.Background())
+	assert.Equal(t, []domain.Product{prod1, prod2}, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	lastID, errResult := repository.LastID(context.Background())
+	assert.Equal(t, 2, lastID, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	active, inactive, errResult := repository.Count(context.Background())
+	assert.Equal(t, 1, active, "deben ser iguales")
+	assert.Equal(t, 1, inactive, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+}
+
+func TestSQLDeleteAndHardDelete(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+	prod := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	_, err := repository.Store(context.Background(), prod.Id, prod.Name, prod.Color, prod.Price, prod.Stock, prod.Code, prod.Published, prod.CreationDate, prod.Active)
+	assert.Nil(t, err, "no debería dar error")
+
+	deletedAt := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	deletedProduct := prod
+	deletedProduct.Active = false
+	deletedProduct.Version = 2
+	deletedProduct.DeletedAt = &deletedAt
+	deletedProduct.DeletedBy = "key-1"
+	result, errResult := repository.Delete(context.Background(), 1, deletedAt, "key-1", AnyVersion)
+	assert.Equal(t, []domain.Product{deletedProduct}, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	result, errResult = repository.HardDelete(context.Background(), 1, AnyVersion)
+	assert.Equal(t, []domain.Product{}, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+}
+
+func TestSQLVersionMismatch(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+	_, err := repository.Store(context.Background(), 1, "prod1", "celeste", 44.44, 222, "KJS4", true, "13-12-2021", true)
+	assert.Nil(t, err, "no debería dar error")
+
+	result, errResult := repository.Update(context.Background(), 1, "After Update", "celeste", 2.0, 2, "2", true, "2", true, time.Time{}, "", 1)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, 2, result.Version, "deben ser iguales")
+
+	_, errResult = repository.Update(context.Background(), 1, "Again", "celeste", 2.0, 2, "2", true, "2", true, time.Time{}, "", 1)
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(errResult), "deben ser iguales")
+
+	_, errResult = repository.HardDelete(context.Background(), 1, 1)
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(errResult), "deben ser iguales")
+
+	_, errResult = repository.HardDelete(context.Background(), 2, 1)
+	assert.Equal(t, errNotFound(2), errResult, "deben ser iguales")
+}
+
+func TestSQLUpdateNotFound(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+
+	result, errResult := repository.Update(context.Background(), 2, "After Update", "celeste", 2.0, 2, "2", true, "2", true, time.Time{}, "", AnyVersion)
+	assert.Equal(t, errNotFound(2), errResult, "deben ser iguales")
+	assert.Equal(t, domain.Product{}, result, "deben ser iguales")
+}
+
+func TestSQLiteStoreReadWrite(t *testing.T) {
+	db := store.New(store.SQLiteType, filepath.Join(t.TempDir(), "products.db"))
+	input := []domain.Product{
+		{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true},
+		{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: false},
+	}
+
+	assert.Nil(t, db.Write(context.Background(), input), "no debería dar error")
+
+	var result []domain.Product
+	assert.Nil(t, db.Read(context.Background(), &result), "no debería dar error")
+	assert.Equal(t, input, result, "deben ser iguales")
+}
+
+func TestSQLNextIDNotReusedAfterHardDelete(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+
+	id, err := repository.NextID(context.Background())
+	assert.Equal(t, 1, id, "deben ser iguales")
+	assert.Nil(t, err, "no debería dar error")
+
+	_, err = repository.Store(context.Background(), id, "prod1", "celeste", 44.44, 222, "KJS4", true, "13-12-2021", true)
+	assert.Nil(t, err, "no debería dar error")
+	_, err = repository.HardDelete(context.Background(), id, AnyVersion)
+	assert.Nil(t, err, "no debería dar error")
+
+	id, err = repository.NextID(context.Background())
+	assert.Equal(t, 2, id, "deben ser iguales")
+	assert.Nil(t, err, "no debería dar error")
+}
+
+func TestSQLGetByID(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+	prod := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	_, err := repository.Store(context.Background(), prod.Id, prod.Name, prod.Color, prod.Price, prod.Stock, prod.Code, prod.Published, prod.CreationDate, prod.Active)
+	assert.Nil(t, err, "no debería dar error")
+
+	result, errResult := repository.GetByID(context.Background(), 1)
+	assert.Equal(t, prod, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	result, errResult = repository.GetByID(context.Background(), 2)
+	assert.Equal(t, domain.Product{}, result, "deben ser iguales")
+	assert.Equal(t, errNotFound(2), errResult, "deben ser iguales")
+}
+
+func TestSQLRestore(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+	prod := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	_, err := repository.Store(context.Background(), prod.Id, prod.Name, prod.Color, prod.Price, prod.Stock, prod.Code, prod.Published, prod.CreationDate, prod.Active)
+	assert.Nil(t, err, "no debería dar error")
+
+	_, errResult := repository.Restore(context.Background(), 1, AnyVersion)
+	assert.Equal(t, errNotDeleted(1), errResult, "deben ser iguales")
+
+	deletedAt := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	_, err = repository.Delete(context.Background(), 1, deletedAt, "key-1", AnyVersion)
+	assert.Nil(t, err, "no debería dar error")
+
+	// Una segunda baja conserva la fecha y el autor de la primera
+	result, err := repository.Delete(context.Background(), 1, deletedAt.Add(time.Hour), "key-2", AnyVersion)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, deletedAt, *result[0].DeletedAt, "deben ser iguales")
+	assert.Equal(t, "key-1", result[0].DeletedBy, "deben ser iguales")
+
+	// Editar un producto en la papelera no lo saca de ella
+	updated, err := repository.Update(context.Background(), 1, prod.Name, prod.Color, 1.5, prod.Stock, prod.Code, prod.Published, prod.CreationDate, false, time.Time{}, "", 3)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, deletedAt, *updated.DeletedAt, "deben ser iguales")
+	assert.Equal(t, "key-1", updated.DeletedBy, "deben ser iguales")
+
+	_, errResult = repository.Restore(context.Background(), 1, 1)
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(errResult), "deben ser iguales")
+
+	_, errResult = repository.Restore(context.Background(), 2, AnyVersion)
+	assert.Equal(t, errNotFound(2), errResult, "deben ser iguales")
+
+	prod.Price = 1.5
+	prod.Version = 5
+	product, errResult := repository.Restore(context.Background(), 1, 4)
+	assert.Equal(t, prod, product, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+}
+
+func TestSQLUniqueCode(t *testing.T) {
+	testUniqueCode(t, func(codeScope CodeScope) Repository {
+		return newSQLRepositoryTest(t, codeScope)
+	})
+}
+
+func TestSQLUpdateDeactivates(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+	prod := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	_, err := repository.Store(context.Background(), prod.Id, prod.Name, prod.Color, prod.Price, prod.Stock, prod.Code, prod.Published, prod.CreationDate, prod.Active)
+	assert.Nil(t, err, "no debería dar error")
+
+	deletedAt := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	updated, err := repository.Update(context.Background(), 1, prod.Name, prod.Color, prod.Price, prod.Stock, prod.Code, prod.Published, prod.CreationDate, false, deletedAt, "key-1", AnyVersion)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, deletedAt, *updated.DeletedAt, "deben ser iguales")
+	assert.Equal(t, "key-1", updated.DeletedBy, "deben ser iguales")
+
+	// La baja ya registrada no cambia
+	updated, err = repository.Update(context.Background(), 1, prod.Name, prod.Color, 1.5, prod.Stock, prod.Code, prod.Published, prod.CreationDate, false, deletedAt.Add(time.Hour), "key-2", AnyVersion)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, deletedAt, *updated.DeletedAt, "deben ser iguales")
+	assert.Equal(t, "key-1", updated.DeletedBy, "deben ser iguales")
+
+	result, err := repository.GetByID(context.Background(), 1)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, updated, result, "deben ser iguales")
+}
+
+func TestSQLBulk(t *testing.T) {
+	repository := newSQLRepositoryTest(t, CodeScopeAll)
+	prod := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	_, err := repository.Store(context.Background(), prod.Id, prod.Name, prod.Color, prod.Price, prod.Stock, prod.Code, prod.Published, prod.CreationDate, prod.Active)
+	assert.Nil(t, err, "no debería dar error")
+
+	created := domain.Product{Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true}
+	ops := []BulkOperation{
+		{Op: OpCreate, Product: created},
+		{Op: OpDelete, Product: domain.Product{Id: 1}, Version: 1, DeletedAt: time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC), DeletedBy: "key-1"},
+		{Op: OpUpdate, Product: prod, Version: 1},
+	}
+
+	results, err := repository.Bulk(context.Background(), ops, BulkOptions{Atomic: true})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(results[2].Err), "deben ser iguales")
+	all, _ := repository.GetAll(context.Background())
+	assert.Equal(t, []domain.Product{prod}, all, "la transacción debe deshacerse")
+
+	results, err = repository.Bulk(context.Background(), ops, BulkOptions{ContinueOnError: true})
+	assert.Nil(t, err, "no debería dar error")
+	assert.True(t, results[0].Applied, "debería aplicarse")
+	assert.Equal(t, "key-1", results[1].Product.DeletedBy, "deben ser iguales")
+	assert.NotNil(t, results[2].Err, "debería dar error")
+
+	all, _ = repository.GetAll(context.Background())
+	assert.Len(t, all, 2, "deben ser iguales")
+	// La reserva de ids se deshace con la transacción descartada
+	assert.Equal(t, 2, all[1].Id, "deben ser iguales")
+}
+
+type observedOperation struct {
+	store, operation string
+	err              error
+}
+
+type observerStub []observedOperation
+
+func (o *observerStub) ObserveStore(store, operation string, d time.Duration, err error) {
+	*o = append(*o, observedOperation{store, operation, err})
+}
+
+func TestSQLInstrumentedRepository(t *testing.T) {
+	db := store.New(store.SQLiteType, filepath.Join(t.TempDir(), "products.db")).(*store.SQLiteStore)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err, "no debería dar error")
+	observer := &observerStub{}
+	repository := NewInstrumentedRepository(NewSQLRepository(sqlDB, CodeScopeAll), "products", observer)
+
+	_, err = repository.Store(context.Background(), 1, "prod1", "celeste", 44.44, 222, "KJS4", true, "13-12-2021", true)
+	assert.Nil(t, err, "no debería dar error")
+	// Un producto inexistente no es una falla del almacenamiento
+	_, err = repository.GetByID(context.Background(), 2)
+	assert.NotNil(t, err, "debería dar error")
+	sqlDB.Close()
+	_, err = repository.GetAll(context.Background())
+	assert.NotNil(t, err, "debería dar error")
+
+	assert.Equal(t, observerStub{
+		{"products", "store", nil},
+		{"products", "get_by_id", nil},
+		{"products", "get_all", err},
+	}, *observer, "deben ser iguales")
+}
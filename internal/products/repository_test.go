@@ -4,21 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
 	"github.com/palomavs/go-web-II/pkg/store"
 	"github.com/stretchr/testify/assert"
 )
 
 const (
-	errorGetAll             = "error for GetAll"
-	errorStore              = "error for Store"
-	errorUpdate             = "error for Update"
-	errorUpdateNameAndPrice = "error for UpdateNameAndPrice"
-	errorHardDelete         = "error for HardDelete"
-	errorDelete             = "error for Delete"
-	errorNotFound           = "producto de id 2 no encontrado"
+	errorGetAll     = "error for GetAll"
+	errorStore      = "error for Store"
+	errorUpdate     = "error for Update"
+	errorHardDelete = "error for HardDelete"
+	errorDelete     = "error for Delete"
+	errorNotFound   = "producto de id 2 no encontrado"
 )
 
 func TestGetAll(t *testing.T) {
@@ -36,7 +40,7 @@ func TestGetAll(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
 	result, errResult := repository.GetAll(context.Background())
 	assert.Equal(t, input, result, "deben ser iguales")
@@ -44,6 +48,31 @@ func TestGetAll(t *testing.T) {
 	assert.True(t, storeMock.Mock.ReadCalled)
 }
 
+func TestCount(t *testing.T) {
+	input := []domain.Product{
+		{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true},
+		{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: false},
+		{Id: 3, Name: "prod3", Color: "verde", Price: 1, Stock: 1, Code: "TR3", Published: false, CreationDate: "13-12-2021", Active: true},
+	}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+
+	active, inactive, err := repository.Count(context.Background())
+	assert.Equal(t, 2, active, "deben ser iguales")
+	assert.Equal(t, 1, inactive, "deben ser iguales")
+	assert.Nil(t, err, "no debería dar error")
+}
+
 func TestGetAllError(t *testing.T) {
 	expectedError := errors.New(errorGetAll)
 	expectedResult := []domain.Product{}
@@ -57,10 +86,10 @@ func TestGetAllError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
 	result, errResult := repository.GetAll(context.Background())
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
 	assert.True(t, storeMock.Mock.ReadCalled)
 }
@@ -78,13 +107,13 @@ func TestStoreError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
 	id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := 1, "prod1", "celeste", 44.40, 222, "K4KH", true, "22-01-22", true
 
 	result, errResult := repository.Store(context.Background(), id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.NotNil(t, errResult, "debería dar error")
 }
 
@@ -101,18 +130,18 @@ func TestUpdateError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
 	id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := 1, "After Update", "celeste", 2.0, 2, "2", true, "2", true
 
-	result, errResult := repository.Update(context.Background(), id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive)
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	result, errResult := repository.Update(context.Background(), id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive, time.Time{}, "", AnyVersion)
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
 }
 
 func TestUpdateNotFound(t *testing.T) {
 	expectedResult := domain.Product{}
-	expectedError := errors.New(errorNotFound)
+	expectedError := errNotFound(2)
 	input := []domain.Product{}
 
 	dataJson, _ := json.Marshal(input)
@@ -125,50 +154,41 @@ func TestUpdateNotFound(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
 	id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := 2, "After Update", "celeste", 2.0, 2, "2", true, "2", true
 
-	result, errResult := repository.Update(context.Background(), id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive)
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	result, errResult := repository.Update(context.Background(), id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive, time.Time{}, "", AnyVersion)
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
+	assert.EqualError(t, errResult, errorNotFound, "deben ser iguales")
 }
 
-func TestUpdateNameAndPrice(t *testing.T) {
-	prod := domain.Product{Id: 1, Name: "Before Change", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true}
-	input := []domain.Product{prod}
+func TestHardDeleteError(t *testing.T) {
+	expectedError := errors.New(errorHardDelete)
 
-	dataJson, _ := json.Marshal(input)
 	dbStub := store.Mock{
-		Data:       dataJson,
-		Err:        nil,
+		Data:       nil,
+		Err:        expectedError,
 		ReadCalled: false,
 	}
 	storeMock := store.FileStore{
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
-	id, newName, newPrice := 1, "After Update", 100.10
-	expectedResult := prod
-	expectedResult.Name = newName
-	expectedResult.Price = newPrice
+	id := 1
+	expectedResult := []domain.Product{}
 
-	result, errResult := repository.UpdateNameAndPrice(context.Background(), id, newName, newPrice)
+	result, errResult := repository.HardDelete(context.Background(), id, AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.Nil(t, errResult, "no debería dar error")
-	assert.True(t, storeMock.Mock.ReadCalled)
-
-	// Validación extra de campos cambiados
-	assert.Equal(t, id, result.Id, "deben ser iguales")
-	assert.Equal(t, newName, result.Name, "deben ser iguales")
-	assert.Equal(t, newPrice, result.Price, "deben ser iguales")
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
+	assert.NotNil(t, errResult, "debería dar error")
 }
 
-func TestUpdateNameAndPriceError(t *testing.T) {
-	expectedError := errors.New(errorUpdateNameAndPrice)
-	expectedResult := domain.Product{}
+func TestDeleteError(t *testing.T) {
+	expectedError := errors.New(errorDelete)
 
 	dbStub := store.Mock{
 		Data:       nil,
@@ -179,18 +199,19 @@ func TestUpdateNameAndPriceError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
-	id, newName, newPrice := 1, "After Update", 100.10
+	id := 1
+	expectedResult := []domain.Product{}
 
-	result, errResult := repository.UpdateNameAndPrice(context.Background(), id, newName, newPrice)
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	result, errResult := repository.Delete(context.Background(), id, time.Now(), "", AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.True(t, storeMock.Mock.ReadCalled)
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
+	assert.NotNil(t, errResult, "debería dar error")
 }
 
-func TestUpdateNameAndPriceNotFound(t *testing.T) {
-	prod := domain.Product{Id: 1, Name: "Before Change", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true}
+func TestUpdateVersionMismatch(t *testing.T) {
+	prod := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 3}
 	input := []domain.Product{prod}
 
 	dataJson, _ := json.Marshal(input)
@@ -203,65 +224,43 @@ func TestUpdateNameAndPriceNotFound(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
-
-	id, newName, newPrice := 2, "After Update", 100.10
-	expectedError := errors.New(errorNotFound)
-	expectedResult := domain.Product{}
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
-	result, errResult := repository.UpdateNameAndPrice(context.Background(), id, newName, newPrice)
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
-	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.True(t, storeMock.Mock.ReadCalled)
-}
-
-func TestHardDeleteError(t *testing.T) {
-	expectedError := errors.New(errorHardDelete)
-
-	dbStub := store.Mock{
-		Data:       nil,
-		Err:        expectedError,
-		ReadCalled: false,
-	}
-	storeMock := store.FileStore{
-		FileName: "",
-		Mock:     &dbStub,
-	}
-	repository := NewRepository(&storeMock)
+	_, errResult := repository.Update(context.Background(), 1, "After Update", "celeste", 1, 1, "KJS4", true, "13-12-2021", true, time.Time{}, "", 2)
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(errResult), "deben ser iguales")
 
-	id := 1
-	expectedResult := []domain.Product{}
+	result, errResult := repository.Update(context.Background(), 1, "After Update", "celeste", 1, 1, "KJS4", true, "13-12-2021", true, time.Time{}, "", 3)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, 4, result.Version, "deben ser iguales")
 
-	result, errResult := repository.HardDelete(context.Background(), id)
-	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
-	assert.NotNil(t, errResult, "debería dar error")
+	_, errResult = repository.Delete(context.Background(), 1, time.Now(), "", 3)
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(errResult), "deben ser iguales")
 }
 
-func TestDeleteError(t *testing.T) {
-	expectedError := errors.New(errorDelete)
+func TestLastID(t *testing.T) {
+	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true}
+	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true}
+	input := []domain.Product{prod1, prod2}
 
+	dataJson, _ := json.Marshal(input)
 	dbStub := store.Mock{
-		Data:       nil,
-		Err:        expectedError,
+		Data:       dataJson,
+		Err:        nil,
 		ReadCalled: false,
 	}
 	storeMock := store.FileStore{
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
-
-	id := 1
-	expectedResult := []domain.Product{}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	expectedResult := 2
 
-	result, errResult := repository.Delete(context.Background(), id)
+	result, errResult := repository.LastID(context.Background())
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
-	assert.NotNil(t, errResult, "debería dar error")
+	assert.Nil(t, errResult, "no debe dar error")
 }
 
-func TestLastID(t *testing.T) {
+func TestNextIDNotReusedAfterHardDelete(t *testing.T) {
 	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true}
 	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true}
 	input := []domain.Product{prod1, prod2}
@@ -276,10 +275,104 @@ func TestLastID(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
-	expectedResult := 2
+	repository := NewRepository(&storeMock, CodeScopeAll)
 
-	result, errResult := repository.LastID(context.Background())
-	assert.Equal(t, expectedResult, result, "deben ser iguales")
+	result, errResult := repository.NextID(context.Background())
+	assert.Equal(t, 3, result, "deben ser iguales")
 	assert.Nil(t, errResult, "no debe dar error")
+
+	_, errResult = repository.HardDelete(context.Background(), 2, AnyVersion)
+	assert.Nil(t, errResult, "no debe dar error")
+
+	result, errResult = repository.NextID(context.Background())
+	assert.Equal(t, 4, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debe dar error")
+}
+
+func TestUniqueCode(t *testing.T) {
+	testUniqueCode(t, func(codeScope CodeScope) Repository {
+		db := store.New(store.FileType, filepath.Join(t.TempDir(), "products.json"))
+		assert.Nil(t, db.Write(context.Background(), []domain.Product{}))
+		return NewRepository(db, codeScope)
+	})
+}
+
+// testUniqueCode verifica la unicidad del código con un repositorio vacío de cada alcance.
+func testUniqueCode(t *testing.T, newRepository func(CodeScope) Repository) {
+	ctx := context.Background()
+	storeCode := func(r Repository, id int, code string, active bool) error {
+		_, err := r.Store(ctx, id, "prod", "celeste", 1, 1, code, true, "13-12-2021", active)
+		return err
+	}
+
+	repository := newRepository(CodeScopeAll)
+	assert.Nil(t, storeCode(repository, 1, "KJS4", true), "no debería dar error")
+	assert.Equal(t, errDuplicateCode("kjs4"), storeCode(repository, 2, "kjs4", true), "no distingue mayúsculas")
+	assert.Nil(t, storeCode(repository, 2, "7UF4", true), "no debería dar error")
+
+	_, err := repository.Update(ctx, 2, "prod", "celeste", 1, 1, "Kjs4", true, "13-12-2021", true, time.Time{}, "", AnyVersion)
+	assert.Equal(t, errDuplicateCode("Kjs4"), err, "deben ser iguales")
+	_, err = repository.Update(ctx, 1, "prod", "celeste", 2, 1, "kjs4", true, "13-12-2021", true, time.Time{}, "", AnyVersion)
+	assert.Nil(t, err, "un producto puede conservar su código")
+
+	_, err = repository.Delete(ctx, 1, time.Now(), "key-1", AnyVersion)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, apperrors.KindConflict, apperrors.KindOf(storeCode(repository, 3, "KJS4", true)), "el código de la papelera sigue ocupado")
+
+	// Con el alcance active el código de un producto dado de baja queda libre
+	repository = newRepository(CodeScopeActive)
+	assert.Nil(t, storeCode(repository, 1, "KJS4", true), "no debería dar error")
+	_, err = repository.Delete(ctx, 1, time.Now(), "key-1", AnyVersion)
+	assert.Nil(t, err, "no debería dar error")
+	assert.Nil(t, storeCode(repository, 2, "kjs4", true), "no debería dar error")
+	assert.Nil(t, storeCode(repository, 3, "KJS4", false), "no debería dar error")
+
+	_, err = repository.Restore(ctx, 1, AnyVersion)
+	assert.Equal(t, apperrors.KindConflict, apperrors.KindOf(err), "otro producto tomó el código")
+
+	products, err := repository.GetByCode(ctx, "Kjs4")
+	assert.Nil(t, err, "no debería dar error")
+	assert.Len(t, products, 3, "deben ser iguales")
+	for i, p := range products {
+		assert.Equal(t, i+1, p.Id, "deben estar ordenados por id")
+	}
+
+	// Dentro de un lote, la baja libera el código para las operaciones siguientes
+	results, err := repository.Bulk(ctx, []BulkOperation{
+		{Op: OpDelete, Product: domain.Product{Id: 2}, Version: AnyVersion, DeletedAt: time.Now(), DeletedBy: "key-1"},
+		{Op: OpCreate, Product: domain.Product{Name: "prod", Color: "celeste", Price: 1, Stock: 1, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true}},
+	}, BulkOptions{Atomic: true})
+	assert.Nil(t, err, "no debería dar error")
+	for _, result := range results {
+		assert.Nil(t, result.Err, "no debería dar error")
+		assert.True(t, result.Applied, "debería aplicarse")
+	}
+}
+
+func TestStoreConcurrentFileStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "products.json")
+	db := store.New(store.FileType, fileName)
+	assert.Nil(t, db.Write(context.Background(), []domain.Product{}))
+	service := NewService(NewRepository(db, CodeScopeAll))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := service.Store(context.Background(), "prod", "celeste", 1, 1, "K4KH"+strconv.Itoa(i), true, "22-01-2022", true)
+			assert.Nil(t, err, "no debería dar error")
+		}(i)
+	}
+	wg.Wait()
+
+	products, err := service.GetAll(context.Background())
+	assert.Nil(t, err, "no debería dar error")
+	assert.Len(t, products, 20, "no se deben perder escrituras")
+
+	ids := map[int]bool{}
+	for _, p := range products {
+		ids[p.Id] = true
+	}
+	assert.Len(t, ids, 20, "los ids deben ser únicos")
 }
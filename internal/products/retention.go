@@ -0,0 +1,28 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	"github.com/palomavs/go-web-II/pkg/logging"
+)
+
+// PurgeTrashEvery purga la papelera al arrancar y después cada interval, hasta que se cancele ctx.
+func PurgeTrashEvery(ctx context.Context, s Service, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := s.PurgeTrash(ctx, retention); err != nil {
+			logging.FromContext(ctx).Error().Err(err).Msg("error al intentar purgar la papelera")
+		} else if purged > 0 {
+			logging.FromContext(ctx).Info().Int("purged", purged).Msg("papelera purgada")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,26 +1,55 @@
 package products
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"time"
 
 	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/jsonpatch"
+	"github.com/palomavs/go-web-II/pkg/logging"
+	"github.com/palomavs/go-web-II/pkg/validation"
 )
 
 type Service interface {
 	GetAll(ctx context.Context) ([]domain.Product, error)
+	Search(ctx context.Context, q Query) (Page, error)
+	GetByID(ctx context.Context, id int, includeInactive bool) (domain.Product, error)
+	// GetByCode busca un producto por código sin distinguir mayúsculas.
+	GetByCode(ctx context.Context, code string, includeInactive bool) (domain.Product, error)
 	Store(ctx context.Context, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error)
-	Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error)
-	UpdateNameAndPrice(ctx context.Context, id int, name string, price float64) (domain.Product, error)
-	HardDelete(ctx context.Context, id int) ([]domain.Product, error)
-	Delete(ctx context.Context, id int) ([]domain.Product, error)
+	// Los métodos que modifican un producto reciben la versión que el llamador leyó (ver Repository).
+	// Si Update o Patch desactivan el producto, la baja queda registrada con deletedBy como responsable.
+	Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedBy string, version int) (domain.Product, error)
+	Patch(ctx context.Context, id int, patch Patch, deletedBy string, version int) (domain.Product, error)
+	HardDelete(ctx context.Context, id int, version int) ([]domain.Product, error)
+	// Delete da de baja el producto (Active=false) registrando a deletedBy como responsable.
+	Delete(ctx context.Context, id int, deletedBy string, version int) ([]domain.Product, error)
+	// Trash lista los productos dados de baja.
+	Trash(ctx context.Context) ([]domain.Product, error)
+	Restore(ctx context.Context, id int, version int) (domain.Product, error)
+	// PurgeTrash borra definitivamente los productos dados de baja hace más de retention y
+	// devuelve cuántos borró.
+	PurgeTrash(ctx context.Context, retention time.Duration) (int, error)
+	// Bulk valida y aplica un lote de operaciones (ver BulkOptions); los delete, y los update que
+	// desactivan un producto, registran a deletedBy como responsable.
+	Bulk(ctx context.Context, ops []BulkOperation, deletedBy string, opts BulkOptions) ([]BulkResult, error)
+	// Import crea o actualiza, según su Code, un producto por cada fila de file. Se guardan todas
+	// las filas o ninguna; con dryRun solo se informa qué pasaría. Las filas que desactivan un
+	// producto registran a deletedBy como responsable de la baja.
+	Import(ctx context.Context, file CSVImport, deletedBy string, dryRun bool) (ImportReport, error)
 }
 
 type service struct {
 	repository Repository
+	now        func() time.Time
 }
 
 func NewService(r Repository) Service {
-	return &service{repository: r}
+	return &service{repository: r, now: time.Now}
 }
 
 func (s *service) GetAll(ctx context.Context) ([]domain.Product, error) {
@@ -32,14 +61,59 @@ func (s *service) GetAll(ctx context.Context) ([]domain.Product, error) {
 	return products, nil
 }
 
+func (s *service) Search(ctx context.Context, q Query) (Page, error) {
+	products, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return q.apply(products)
+}
+
+// GetByID trata a los productos dados de baja (Active=false) como inexistentes,
+// salvo que se pida incluirlos explícitamente.
+func (s *service) GetByID(ctx context.Context, id int, includeInactive bool) (domain.Product, error) {
+	product, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	if !product.Active && !includeInactive {
+		return domain.Product{}, errNotFound(id)
+	}
+	return product, nil
+}
+
+// GetByCode elige entre los productos con el código como pickByCode.
+func (s *service) GetByCode(ctx context.Context, code string, includeInactive bool) (domain.Product, error) {
+	products, err := s.repository.GetByCode(ctx, code)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	found, err := pickByCode(code, products)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	if found == nil || (!found.Active && !includeInactive) {
+		return domain.Product{}, apperrors.NotFound("product_code_not_found", code)
+	}
+	return *found, nil
+}
+
 func (s *service) Store(ctx context.Context, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error) {
-	lastID, err := s.repository.LastID(ctx)
+	code = normalizeCode(code)
+	product := domain.Product{Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active}
+	if err := validation.Struct(product); err != nil {
+		return domain.Product{}, err
+	}
+
+	id, err := s.repository.NextID(ctx)
 	if err != nil {
 		return domain.Product{}, err
 	}
-	lastID++
 
-	newProduct, err := s.repository.Store(ctx, lastID, name, color, price, stock, code, published, creationDate, active)
+	newProduct, err := s.repository.Store(ctx, id, name, color, price, stock, code, published, creationDate, active)
 	if err != nil {
 		return domain.Product{}, err
 	}
@@ -47,18 +121,323 @@ func (s *service) Store(ctx context.Context, name, color string, price float64,
 	return newProduct, nil
 }
 
-func (s *service) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (domain.Product, error) {
-	return s.repository.Update(ctx, id, name, color, price, stock, code, published, creationDate, active)
+func (s *service) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedBy string, version int) (domain.Product, error) {
+	code = normalizeCode(code)
+	product := domain.Product{Id: id, Name: name, Color: color, Price: price, Stock: stock, Code: code, Published: published, CreationDate: creationDate, Active: active}
+	if err := validation.Struct(product); err != nil {
+		return domain.Product{}, err
+	}
+
+	return s.repository.Update(ctx, id, name, color, price, stock, code, published, creationDate, active, s.now().UTC(), deletedBy, version)
+}
+
+func (s *service) HardDelete(ctx context.Context, id int, version int) ([]domain.Product, error) {
+	products, err := s.repository.HardDelete(ctx, id, version)
+	if err == nil {
+		// El borrado definitivo no se puede deshacer: queda en el log con el id de la petición
+		logging.FromContext(ctx).Info().Int("productId", id).Msg("producto borrado definitivamente")
+	}
+	return products, err
+}
+
+func (s *service) Delete(ctx context.Context, id int, deletedBy string, version int) ([]domain.Product, error) {
+	return s.repository.Delete(ctx, id, s.now().UTC(), deletedBy, version)
+}
+
+func (s *service) Trash(ctx context.Context) ([]domain.Product, error) {
+	products, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trash := []domain.Product{}
+	for _, p := range products {
+		if !p.Active {
+			trash = append(trash, p)
+		}
+	}
+	return trash, nil
+}
+
+func (s *service) Restore(ctx context.Context, id int, version int) (domain.Product, error) {
+	return s.repository.Restore(ctx, id, version)
+}
+
+// PurgeTrash solo considera los productos con DeletedAt. Cada borrado exige la versión leída,
+// así que un producto restaurado o modificado mientras tanto no se borra.
+func (s *service) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	products, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := s.now().Add(-retention)
+	purged := 0
+	for _, p := range products {
+		if p.Active || p.DeletedAt == nil || !p.DeletedAt.Before(cutoff) {
+			continue
+		}
+
+		_, err := s.repository.HardDelete(ctx, p.Id, p.Version)
+		switch apperrors.KindOf(err) {
+		case apperrors.KindPreconditionFailed, apperrors.KindNotFound:
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+		logging.FromContext(ctx).Info().Int("productId", p.Id).Time("deletedAt", *p.DeletedAt).Str("deletedBy", p.DeletedBy).Msg("producto borrado definitivamente por vencer su retención en la papelera")
+	}
+	return purged, nil
+}
+
+// Bulk valida todas las operaciones antes de pasar al repositorio las que corresponde ejecutar:
+// en modo atómico, ninguna si alguna es inválida; si no, las válidas hasta la primera inválida o,
+// con ContinueOnError, todas las válidas.
+func (s *service) Bulk(ctx context.Context, ops []BulkOperation, deletedBy string, opts BulkOptions) ([]BulkResult, error) {
+	results := make([]BulkResult, len(ops))
+	var valid []BulkOperation
+	var positions []int
+	invalid := false
+	now := s.now().UTC()
+	for i, op := range ops {
+		op.Product.Code = normalizeCode(op.Product.Code)
+		if err := validateOperation(op); err != nil {
+			results[i].Err = err
+			invalid = true
+			if !opts.ContinueOnError {
+				break
+			}
+			continue
+		}
+		if op.Op == OpDelete || op.Op == OpUpdate {
+			op.DeletedAt, op.DeletedBy = now, deletedBy
+		}
+		valid = append(valid, op)
+		positions = append(positions, i)
+	}
+	if (invalid && opts.Atomic) || len(valid) == 0 {
+		return results, nil
+	}
+
+	applied, err := s.repository.Bulk(ctx, valid, opts)
+	if err != nil {
+		return nil, err
+	}
+	stopped := false
+	for j, result := range applied {
+		results[positions[j]] = result
+		stopped = stopped || result.Err != nil
+	}
+	// Sin ContinueOnError, una falla en el repositorio deja sin ejecutar a la operación inválida
+	if stopped && !opts.ContinueOnError {
+		for i := positions[len(positions)-1] + 1; i < len(results); i++ {
+			results[i].Err = nil
+		}
+	}
+	return results, nil
+}
+
+// Import actualiza solo las columnas presentes en el archivo; los productos nuevos empiezan
+// activos si el archivo no tiene la columna active. Las filas iguales al producto guardado no
+// generan cambios.
+func (s *service) Import(ctx context.Context, file CSVImport, deletedBy string, dryRun bool) (ImportReport, error) {
+	products, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	byCode := map[string][]domain.Product{}
+	for _, p := range products {
+		key := codeKey(p.Code)
+		byCode[key] = append(byCode[key], p)
+	}
+
+	report := ImportReport{Results: make([]ImportResult, len(file.Rows))}
+	var ops []BulkOperation
+	var positions []int
+	lines := map[string]int{}
+	for i, row := range file.Rows {
+		result := &report.Results[i]
+		result.Line = row.Line
+		row.Product.Code = normalizeCode(row.Product.Code)
+		key := codeKey(row.Product.Code)
+		// Se actualiza el mismo producto que devuelve GetByCode
+		existing, errCode := pickByCode(row.Product.Code, byCode[key])
+
+		switch {
+		case row.Err != nil:
+			result.Err = row.Err
+		case lines[key] != 0:
+			result.Err = apperrors.Validation("duplicate_row", row.Product.Code, lines[key])
+		case errCode != nil:
+			result.Err = errCode
+		case existing != nil:
+			result.Product = *existing
+			copyColumns(&result.Product, row.Product, file.Columns)
+			result.Action = ImportUpdate
+			if result.Product == *existing {
+				result.Action = ImportUnchanged
+			}
+		default:
+			result.Product = domain.Product{Active: true}
+			copyColumns(&result.Product, row.Product, file.Columns)
+			result.Action = ImportCreate
+		}
+		if key != "" && lines[key] == 0 {
+			lines[key] = row.Line
+		}
+		if result.Err == nil && result.Action != ImportUnchanged {
+			result.Err = validation.Struct(result.Product)
+		}
+
+		if result.Err != nil {
+			result.Action = ""
+			continue
+		}
+		switch result.Action {
+		case ImportCreate:
+			ops = append(ops, BulkOperation{Op: OpCreate, Product: result.Product})
+		case ImportUpdate:
+			ops = append(ops, BulkOperation{Op: OpUpdate, Product: result.Product, Version: existing.Version, DeletedAt: s.now().UTC(), DeletedBy: deletedBy})
+		}
+		if result.Action != ImportUnchanged {
+			positions = append(positions, i)
+		}
+	}
+	report.count()
+	if dryRun || report.Failed > 0 || len(ops) == 0 {
+		report.Applied = !dryRun && report.Failed == 0
+		return report, nil
+	}
+
+	applied, err := s.repository.Bulk(ctx, ops, BulkOptions{Atomic: true, ContinueOnError: true})
+	if err != nil {
+		return ImportReport{}, err
+	}
+	for j, result := range applied {
+		row := &report.Results[positions[j]]
+		if result.Err != nil {
+			// Otro cambio se adelantó entre la lectura y la escritura
+			row.Action, row.Err = "", result.Err
+			continue
+		}
+		row.Product = result.Product
+	}
+	report.count()
+	report.Applied = report.Failed == 0
+	return report, nil
+}
+
+func validateOperation(op BulkOperation) error {
+	switch op.Op {
+	case OpCreate, OpUpdate:
+		return validation.Struct(op.Product)
+	case OpDelete:
+		return nil
+	}
+	return errInvalidOperation(op.Op)
 }
 
-func (s *service) HardDelete(ctx context.Context, id int) ([]domain.Product, error) {
-	return s.repository.HardDelete(ctx, id)
+// Patch aplica patch sobre la representación JSON del producto id y guarda el resultado, que se
+// valida como un producto completo. El id y la versión no se pueden modificar. Si el llamador no
+// fija la versión y otro cambio se adelanta entre la lectura y la escritura, el patch se vuelve a
+// aplicar sobre el producto actualizado.
+func (s *service) Patch(ctx context.Context, id int, patch Patch, deletedBy string, version int) (domain.Product, error) {
+	for attempt := 1; ; attempt++ {
+		current, err := s.repository.GetByID(ctx, id)
+		if err != nil {
+			return domain.Product{}, err
+		}
+		if err := checkVersion(current, version); err != nil {
+			return domain.Product{}, err
+		}
+
+		p, err := applyPatch(current, patch)
+		if err != nil {
+			return domain.Product{}, err
+		}
+		p.Code = normalizeCode(p.Code)
+		if err := validation.Struct(p); err != nil {
+			return domain.Product{}, err
+		}
+
+		updated, err := s.repository.Update(ctx, id, p.Name, p.Color, p.Price, p.Stock, p.Code, p.Published, p.CreationDate, p.Active, s.now().UTC(), deletedBy, current.Version)
+		if version == AnyVersion && attempt < maxPatchAttempts && apperrors.KindOf(err) == apperrors.KindPreconditionFailed {
+			continue
+		}
+		return updated, err
+	}
 }
 
-func (s *service) Delete(ctx context.Context, id int) ([]domain.Product, error) {
-	return s.repository.Delete(ctx, id)
+// Patch es un cambio parcial sobre la representación JSON de un producto, como
+// jsonpatch.MergePatch o jsonpatch.Patch.
+type Patch interface {
+	Apply(doc []byte) ([]byte, error)
 }
 
-func (s *service) UpdateNameAndPrice(ctx context.Context, id int, name string, price float64) (domain.Product, error) {
-	return s.repository.UpdateNameAndPrice(ctx, id, name, price)
+// maxPatchAttempts limita los reintentos de Patch cuando el producto cambia mientras se aplica.
+const maxPatchAttempts = 3
+
+func applyPatch(current domain.Product, patch Patch) (domain.Product, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	patched, err := patch.Apply(doc)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return domain.Product{}, apperrors.Conflict("patch_test_failed", err.Error())
+	}
+	if err != nil {
+		return domain.Product{}, apperrors.Validation("invalid_patch", err.Error())
+	}
+
+	var p domain.Product
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return domain.Product{}, apperrors.Validation("invalid_patch", err.Error())
+	}
+
+	if p.Id != current.Id {
+		return domain.Product{}, apperrors.Validation("read_only_field", "id")
+	}
+	if p.Version != current.Version {
+		return domain.Product{}, apperrors.Validation("read_only_field", "version")
+	}
+	if p.DeletedBy != current.DeletedBy || !sameTime(p.DeletedAt, current.DeletedAt) {
+		return domain.Product{}, apperrors.Validation("read_only_field", "deletedAt")
+	}
+	return p, nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func errNotFound(id int) error {
+	return apperrors.NotFound("product_not_found", id)
+}
+
+func errInvalidOperation(op string) error {
+	return apperrors.Validation("invalid_operation", op)
+}
+
+func errNotDeleted(id int) error {
+	return apperrors.Conflict("product_not_deleted", id)
+}
+
+// AnyVersion, como versión esperada, modifica el producto sin importar su versión actual. Los
+// productos guardados antes de que existiera Version están en la versión 0.
+const AnyVersion = -1
+
+func checkVersion(p domain.Product, version int) error {
+	if version >= 0 && p.Version != version {
+		return apperrors.PreconditionFailed("version_mismatch", p.Id)
+	}
+	return nil
 }
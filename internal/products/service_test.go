@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/jsonpatch"
 	"github.com/palomavs/go-web-II/pkg/store"
 	"github.com/stretchr/testify/assert"
 )
@@ -26,7 +29,7 @@ func TestServiceGetAll(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
 	result, err := service.GetAll(context.Background())
@@ -47,11 +50,11 @@ func TestServiceGetAllError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
 	result, errResult := service.GetAll(context.Background())
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.Nil(t, result, "debe dar nil")
 	assert.True(t, storeMock.Mock.ReadCalled)
 }
@@ -69,11 +72,11 @@ func TestServiceStore(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
-	id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := 1, "prod1", "celeste", 44.40, 222, "K4KH", true, "22-01-22", true
-	expectedResult := domain.Product{Id: id, Name: newName, Color: newColor, Price: newPrice, Stock: newStock, Code: newCode, Published: newPublished, CreationDate: newDate, Active: newActive}
+	id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := 1, "prod1", "celeste", 44.40, 222, "K4KH", true, "22-01-2022", true
+	expectedResult := domain.Product{Id: id, Name: newName, Color: newColor, Price: newPrice, Stock: newStock, Code: newCode, Published: newPublished, CreationDate: newDate, Active: newActive, Version: 1}
 
 	result, errResult := service.Store(context.Background(), newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
@@ -93,14 +96,14 @@ func TestServiceStoreError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
-	newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := "prod1", "celeste", 44.40, 222, "K4KH", true, "22-01-22", true
+	newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := "prod1", "celeste", 44.40, 222, "K4KH", true, "22-01-2022", true
 
 	result, errResult := service.Store(context.Background(), newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.NotNil(t, errResult, "debería dar error")
 }
 
@@ -118,20 +121,20 @@ func TestServiceUpdate(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
-	id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := 1, "After Update", "celeste", 2.0, 2, "2", true, "2", true
-	expectedResult := domain.Product{Id: id, Name: newName, Color: newColor, Price: newPrice, Stock: newStock, Code: newCode, Published: newPublished, CreationDate: newDate, Active: newActive}
+	id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive := 1, "After Update", "celeste", 2.0, 2, "2", true, "2-2-2022", true
+	expectedResult := domain.Product{Id: id, Name: newName, Color: newColor, Price: newPrice, Stock: newStock, Code: newCode, Published: newPublished, CreationDate: newDate, Active: newActive, Version: 1}
 
-	result, errResult := service.Update(context.Background(), id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive)
+	result, errResult := service.Update(context.Background(), id, newName, newColor, newPrice, newStock, newCode, newPublished, newDate, newActive, "key-1", AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
 	assert.Nil(t, errResult, "no debería dar error")
 	assert.True(t, storeMock.Mock.ReadCalled)
 }
 
-func TestServiceUpdateNameAndPrice(t *testing.T) {
-	prod := domain.Product{Id: 1, Name: "Before Change", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true}
+func TestServicePatch(t *testing.T) {
+	prod := domain.Product{Id: 1, Name: "Before Change", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true, Version: 2}
 	input := []domain.Product{prod}
 
 	dataJson, _ := json.Marshal(input)
@@ -144,17 +147,69 @@ func TestServiceUpdateNameAndPrice(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
-	id, newName, newPrice := 1, "After Update", 100.10
+	mergePatch, _ := jsonpatch.NewMergePatch([]byte(`{"stock": 10}`))
 	expectedResult := prod
-	expectedResult.Name = newName
-	expectedResult.Price = newPrice
+	expectedResult.Stock = 10
+	expectedResult.Version = 3
+
+	result, errResult := service.Patch(context.Background(), 1, mergePatch, "key-1", 2)
+	assert.Equal(t, expectedResult, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	patch, _ := jsonpatch.Decode([]byte(`[{"op":"test","path":"/stock","value":10},{"op":"replace","path":"/color","value":"rojo"}]`))
+	expectedResult.Color = "rojo"
+	expectedResult.Version = 4
 
-	result, errResult := service.UpdateNameAndPrice(context.Background(), id, newName, newPrice)
+	result, errResult = service.Patch(context.Background(), 1, patch, "key-1", AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
 	assert.Nil(t, errResult, "no debería dar error")
+
+	_, errResult = service.Patch(context.Background(), 1, mergePatch, "key-1", 2)
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(errResult), "deben ser iguales")
+}
+
+func TestServicePatchErrors(t *testing.T) {
+	prod := domain.Product{Id: 1, Name: "Before Change", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true}
+	input := []domain.Product{prod}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	service := NewService(repository)
+
+	cases := []struct {
+		patch string
+		kind  apperrors.Kind
+	}{
+		{`[{"op":"test","path":"/stock","value":1}]`, apperrors.KindConflict},
+		{`[{"op":"remove","path":"/nope"}]`, apperrors.KindValidation},
+		{`[{"op":"replace","path":"/id","value":2}]`, apperrors.KindValidation},
+		{`[{"op":"add","path":"/weight","value":2}]`, apperrors.KindValidation},
+		{`[{"op":"replace","path":"/price","value":"caro"}]`, apperrors.KindValidation},
+		{`[{"op":"replace","path":"/price","value":-1}]`, apperrors.KindValidation},
+	}
+	for _, c := range cases {
+		patch, err := jsonpatch.Decode([]byte(c.patch))
+		assert.Nil(t, err, "no debería dar error")
+
+		_, errResult := service.Patch(context.Background(), 1, patch, "key-1", AnyVersion)
+		assert.Equal(t, c.kind, apperrors.KindOf(errResult), c.patch)
+	}
+
+	mergePatch, _ := jsonpatch.NewMergePatch([]byte(`{"name": "x"}`))
+	_, errResult := service.Patch(context.Background(), 2, mergePatch, "key-1", AnyVersion)
+	assert.Equal(t, errNotFound(2), errResult, "deben ser iguales")
 }
 
 func TestServiceHardDelete(t *testing.T) {
@@ -171,13 +226,13 @@ func TestServiceHardDelete(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
 	id := 1
 	expectedResult := []domain.Product{}
 
-	result, errResult := service.HardDelete(context.Background(), id)
+	result, errResult := service.HardDelete(context.Background(), id, AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
 	assert.Nil(t, errResult, "no debería dar error")
 }
@@ -196,16 +251,16 @@ func TestServiceHardDeleteError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
 	id := 2
 	expectedResult := []domain.Product{}
-	expectedError := errors.New(errorNotFound)
+	expectedError := errNotFound(2)
 
-	result, errResult := service.HardDelete(context.Background(), id)
+	result, errResult := service.HardDelete(context.Background(), id, AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.NotNil(t, errResult, "debería dar error")
 }
 
@@ -223,15 +278,20 @@ func TestServiceDelete(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	setNow(service, now)
 
 	id := 1
 	deletedProduct := prod
 	deletedProduct.Active = false
+	deletedProduct.Version = 1
+	deletedProduct.DeletedAt = &now
+	deletedProduct.DeletedBy = "key-1"
 	expectedResult := []domain.Product{deletedProduct}
 
-	result, errResult := service.Delete(context.Background(), id)
+	result, errResult := service.Delete(context.Background(), id, "key-1", AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
 	assert.Nil(t, errResult, "no debería dar error")
 }
@@ -250,15 +310,299 @@ func TestServiceDeleteError(t *testing.T) {
 		FileName: "",
 		Mock:     &dbStub,
 	}
-	repository := NewRepository(&storeMock)
+	repository := NewRepository(&storeMock, CodeScopeAll)
 	service := NewService(repository)
 
 	id := 2
 	expectedResult := []domain.Product{}
-	expectedError := errors.New(errorNotFound)
+	expectedError := errNotFound(2)
 
-	result, errResult := service.Delete(context.Background(), id)
+	result, errResult := service.Delete(context.Background(), id, "", AnyVersion)
 	assert.Equal(t, expectedResult, result, "deben ser iguales")
-	assert.Equal(t, expectedError, errResult, "deben ser iguales")
+	assert.Equal(t, apperrors.Unavailable(expectedError), errResult, "deben ser iguales")
 	assert.NotNil(t, errResult, "debería dar error")
 }
+
+func TestServiceGetByIDInactive(t *testing.T) {
+	prod := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: false}
+	input := []domain.Product{prod}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	service := NewService(repository)
+
+	result, errResult := service.GetByID(context.Background(), 1, false)
+	assert.Equal(t, domain.Product{}, result, "deben ser iguales")
+	assert.Equal(t, errNotFound(1), errResult, "deben ser iguales")
+
+	result, errResult = service.GetByID(context.Background(), 1, true)
+	assert.Equal(t, prod, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	result, errResult = service.GetByID(context.Background(), 2, true)
+	assert.Equal(t, domain.Product{}, result, "deben ser iguales")
+	assert.Equal(t, errNotFound(2), errResult, "deben ser iguales")
+}
+
+func TestServiceGetByCode(t *testing.T) {
+	deleted := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: false}
+	active := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "kjs4", Published: false, CreationDate: "13-12-2021", Active: true}
+	older := domain.Product{Id: 3, Name: "prod3", Color: "rojo", Price: 10, Stock: 1, Code: "ZZ12", Published: true, CreationDate: "13-12-2021", Active: false}
+	newer := older
+	newer.Id = 4
+	input := []domain.Product{deleted, active, older, newer}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeActive)
+	service := NewService(repository)
+
+	// El producto activo tiene prioridad aunque se pidan los dados de baja
+	result, errResult := service.GetByCode(context.Background(), "Kjs4", true)
+	assert.Equal(t, active, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	result, errResult = service.GetByCode(context.Background(), "zz12", true)
+	assert.Equal(t, newer, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	result, errResult = service.GetByCode(context.Background(), "ZZ12", false)
+	assert.Equal(t, domain.Product{}, result, "deben ser iguales")
+	assert.Equal(t, apperrors.KindNotFound, apperrors.KindOf(errResult), "deben ser iguales")
+}
+
+func TestServiceStoreValidation(t *testing.T) {
+	dataJson, _ := json.Marshal([]domain.Product{})
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	service := NewService(repository)
+
+	result, errResult := service.Store(context.Background(), "", "celeste", -1, 0, "k-4", true, "2022-01-22", true)
+	assert.Equal(t, domain.Product{}, result, "deben ser iguales")
+	assert.Equal(t, apperrors.KindValidation, apperrors.KindOf(errResult), "debe ser un error de validación")
+	assert.Equal(t, []apperrors.FieldError{
+		{Field: "name", Code: "required", Message: "es obligatorio"},
+		{Field: "price", Code: "min", Message: "debe ser mayor o igual a 0", Param: "0"},
+		{Field: "code", Code: "pattern", Message: "no tiene un formato válido"},
+		{Field: "creationDate", Code: "date", Message: "debe ser una fecha con el formato de 13-12-2021", Param: "13-12-2021"},
+	}, apperrors.FieldsOf(errResult), "deben ser iguales")
+	assert.False(t, dbStub.ReadCalled, "no debería llegar al repositorio")
+
+	// Precio y stock en 0 son valores legítimos
+	result, errResult = service.Store(context.Background(), "prod1", "celeste", 0, 0, "K4KH", true, "22-01-2022", true)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, 0.0, result.Price, "deben ser iguales")
+
+	// El código se guarda en mayúsculas y choca con el mismo código en otra forma
+	result, errResult = service.Store(context.Background(), "prod2", "celeste", 1, 1, "ab12", true, "22-01-2022", true)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, "AB12", result.Code, "deben ser iguales")
+	_, errResult = service.Store(context.Background(), "prod3", "celeste", 1, 1, "Ab12", true, "22-01-2022", true)
+	assert.Equal(t, apperrors.KindConflict, apperrors.KindOf(errResult), "debe ser un conflicto")
+}
+
+func setNow(s Service, now time.Time) {
+	s.(*service).now = func() time.Time { return now }
+}
+
+func TestServiceTrashAndRestore(t *testing.T) {
+	deletedAt := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: false, Version: 2, DeletedAt: &deletedAt, DeletedBy: "key-1"}
+	input := []domain.Product{prod1, prod2}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	service := NewService(NewRepository(&storeMock, CodeScopeAll))
+
+	result, errResult := service.Trash(context.Background())
+	assert.Equal(t, []domain.Product{prod2}, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	_, errResult = service.Restore(context.Background(), 1, AnyVersion)
+	assert.Equal(t, errNotDeleted(1), errResult, "deben ser iguales")
+
+	_, errResult = service.Restore(context.Background(), 2, 1)
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(errResult), "deben ser iguales")
+
+	restored := prod2
+	restored.Active = true
+	restored.Version = 3
+	restored.DeletedAt = nil
+	restored.DeletedBy = ""
+	product, errResult := service.Restore(context.Background(), 2, 2)
+	assert.Equal(t, restored, product, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	result, errResult = service.Trash(context.Background())
+	assert.Equal(t, []domain.Product{}, result, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+}
+
+func TestServicePurgeTrash(t *testing.T) {
+	now := time.Date(2022, 6, 10, 12, 0, 0, 0, time.UTC)
+	old, recent := now.Add(-40*24*time.Hour), now.Add(-time.Hour)
+	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: false, Version: 2, DeletedAt: &old}
+	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: false, Version: 2, DeletedAt: &recent}
+	// Dado de baja antes de que existiera la papelera: no se sabe desde cuándo, no se purga
+	prod3 := domain.Product{Id: 3, Name: "prod3", Color: "rojo", Price: 10, Stock: 1, Code: "ZZ12", Published: false, CreationDate: "13-12-2021", Active: false}
+	input := []domain.Product{prod1, prod2, prod3}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	service := NewService(repository)
+	setNow(service, now)
+
+	purged, errResult := service.PurgeTrash(context.Background(), 30*24*time.Hour)
+	assert.Equal(t, 1, purged, "deben ser iguales")
+	assert.Nil(t, errResult, "no debería dar error")
+
+	result, _ := repository.GetAll(context.Background())
+	assert.Equal(t, []domain.Product{prod2, prod3}, result, "deben ser iguales")
+}
+
+func TestServicePurgeTrash_DeactivatedByUpdate(t *testing.T) {
+	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true, Version: 1}
+	input := []domain.Product{prod1, prod2}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	service := NewService(repository)
+	deactivatedAt := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	setNow(service, deactivatedAt)
+
+	// Desactivar con Update o Patch registra la baja como Delete
+	result, errResult := service.Update(context.Background(), 1, prod1.Name, prod1.Color, prod1.Price, prod1.Stock, prod1.Code, prod1.Published, prod1.CreationDate, false, "key-1", AnyVersion)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, &deactivatedAt, result.DeletedAt, "deben ser iguales")
+	assert.Equal(t, "key-1", result.DeletedBy, "deben ser iguales")
+	result, errResult = service.Patch(context.Background(), 2, jsonpatch.MergePatch(`{"active":false}`), "key-2", AnyVersion)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, "key-2", result.DeletedBy, "deben ser iguales")
+
+	// Editar un producto ya dado de baja conserva la baja original
+	setNow(service, deactivatedAt.Add(time.Hour))
+	result, errResult = service.Update(context.Background(), 1, prod1.Name, prod1.Color, 1.5, prod1.Stock, prod1.Code, prod1.Published, prod1.CreationDate, false, "key-2", AnyVersion)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, &deactivatedAt, result.DeletedAt, "deben ser iguales")
+	assert.Equal(t, "key-1", result.DeletedBy, "deben ser iguales")
+
+	setNow(service, deactivatedAt.Add(31*24*time.Hour))
+	purged, errResult := service.PurgeTrash(context.Background(), 30*24*time.Hour)
+	assert.Nil(t, errResult, "no debería dar error")
+	assert.Equal(t, 2, purged, "deben ser iguales")
+}
+
+func TestServiceBulk(t *testing.T) {
+	prod1 := domain.Product{Id: 1, Name: "prod1", Color: "celeste", Price: 44.44, Stock: 222, Code: "KJS4", Published: true, CreationDate: "13-12-2021", Active: true, Version: 1}
+	prod2 := domain.Product{Id: 2, Name: "prod2", Color: "azul", Price: 14.14, Stock: 672, Code: "7UF4", Published: false, CreationDate: "13-12-2021", Active: true, Version: 1}
+	input := []domain.Product{prod1, prod2}
+
+	dataJson, _ := json.Marshal(input)
+	dbStub := store.Mock{
+		Data:       dataJson,
+		Err:        nil,
+		ReadCalled: false,
+	}
+	storeMock := store.FileStore{
+		FileName: "",
+		Mock:     &dbStub,
+	}
+	repository := NewRepository(&storeMock, CodeScopeAll)
+	service := NewService(repository)
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	setNow(service, now)
+
+	created := domain.Product{Name: "prod3", Color: "rojo", Price: 10, Stock: 1, Code: "ZZ12", Published: true, CreationDate: "13-12-2021", Active: true}
+	updated := prod1
+	updated.Price = 50
+	ops := []BulkOperation{
+		{Op: OpCreate, Product: created},
+		{Op: OpUpdate, Product: updated, Version: 2},
+		{Op: OpCreate, Product: domain.Product{Name: "sin código"}},
+		{Op: OpDelete, Product: domain.Product{Id: 2}, Version: AnyVersion},
+	}
+
+	// Atómico: la versión vencida descarta el lote completo
+	results, err := service.Bulk(context.Background(), ops, "key-1", BulkOptions{Atomic: true, ContinueOnError: true})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, apperrors.KindValidation, apperrors.KindOf(results[2].Err), "deben ser iguales")
+	for _, result := range results {
+		assert.False(t, result.Applied, "no debería aplicarse")
+	}
+	all, _ := repository.GetAll(context.Background())
+	assert.Equal(t, input, all, "deben ser iguales")
+
+	// Sin ContinueOnError se detiene en la primera falla y guarda lo anterior
+	results, err = service.Bulk(context.Background(), ops, "key-1", BulkOptions{})
+	assert.Nil(t, err, "no debería dar error")
+	assert.True(t, results[0].Applied, "debería aplicarse")
+	assert.Equal(t, apperrors.KindPreconditionFailed, apperrors.KindOf(results[1].Err), "deben ser iguales")
+	assert.Nil(t, results[2].Err, "no debería ejecutarse")
+	assert.False(t, results[3].Applied, "no debería ejecutarse")
+
+	// El create del primer intento ya ocupó el código ZZ12
+	ops[1].Version = 1
+	results, err = service.Bulk(context.Background(), ops, "key-1", BulkOptions{ContinueOnError: true})
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, apperrors.KindConflict, apperrors.KindOf(results[0].Err), "deben ser iguales")
+	assert.Equal(t, 2, results[1].Product.Version, "deben ser iguales")
+	assert.NotNil(t, results[2].Err, "debería dar error")
+	assert.Equal(t, &now, results[3].Product.DeletedAt, "deben ser iguales")
+	assert.Equal(t, "key-1", results[3].Product.DeletedBy, "deben ser iguales")
+
+	all, _ = repository.GetAll(context.Background())
+	assert.Len(t, all, 3, "no debe repetirse el create")
+}
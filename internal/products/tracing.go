@@ -0,0 +1,241 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	"github.com/palomavs/go-web-II/internal/domain"
+	"github.com/palomavs/go-web-II/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func productID(id int) attribute.KeyValue {
+	return attribute.Int("product.id", id)
+}
+
+func productCode(code string) attribute.KeyValue {
+	return attribute.String("product.code", code)
+}
+
+func bulkAttributes(ops []BulkOperation, opts BulkOptions) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("bulk.operations", len(ops)),
+		attribute.Bool("bulk.atomic", opts.Atomic),
+		attribute.Bool("bulk.continue_on_error", opts.ContinueOnError),
+	}
+}
+
+type tracedService struct {
+	next Service
+}
+
+// NewTracedService devuelve un Service que crea un span por cada método de s.
+func NewTracedService(s Service) Service {
+	return &tracedService{next: s}
+}
+
+func (s *tracedService) GetAll(ctx context.Context) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.GetAll")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.GetAll(ctx)
+}
+
+func (s *tracedService) Search(ctx context.Context, q Query) (page Page, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Search")
+	defer func() {
+		span.SetAttributes(attribute.Int("products.count", len(page.Products)))
+		tracing.End(span, err)
+	}()
+
+	return s.next.Search(ctx, q)
+}
+
+func (s *tracedService) GetByID(ctx context.Context, id int, includeInactive bool) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.GetByID", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.GetByID(ctx, id, includeInactive)
+}
+
+func (s *tracedService) GetByCode(ctx context.Context, code string, includeInactive bool) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.GetByCode", productCode(code))
+	defer func() {
+		span.SetAttributes(productID(product.Id))
+		tracing.End(span, err)
+	}()
+
+	return s.next.GetByCode(ctx, code, includeInactive)
+}
+
+func (s *tracedService) Store(ctx context.Context, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Store")
+	defer func() {
+		span.SetAttributes(productID(product.Id))
+		tracing.End(span, err)
+	}()
+
+	return s.next.Store(ctx, name, color, price, stock, code, published, creationDate, active)
+}
+
+func (s *tracedService) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedBy string, version int) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Update", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Update(ctx, id, name, color, price, stock, code, published, creationDate, active, deletedBy, version)
+}
+
+func (s *tracedService) Patch(ctx context.Context, id int, patch Patch, deletedBy string, version int) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Patch", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Patch(ctx, id, patch, deletedBy, version)
+}
+
+func (s *tracedService) HardDelete(ctx context.Context, id int, version int) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.HardDelete", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.HardDelete(ctx, id, version)
+}
+
+func (s *tracedService) Delete(ctx context.Context, id int, deletedBy string, version int) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Delete", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Delete(ctx, id, deletedBy, version)
+}
+
+func (s *tracedService) Trash(ctx context.Context) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Trash")
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Trash(ctx)
+}
+
+func (s *tracedService) Restore(ctx context.Context, id int, version int) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Restore", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Restore(ctx, id, version)
+}
+
+func (s *tracedService) PurgeTrash(ctx context.Context, retention time.Duration) (purged int, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.PurgeTrash")
+	defer func() {
+		span.SetAttributes(attribute.Int("products.purged", purged))
+		tracing.End(span, err)
+	}()
+
+	return s.next.PurgeTrash(ctx, retention)
+}
+
+func (s *tracedService) Bulk(ctx context.Context, ops []BulkOperation, deletedBy string, opts BulkOptions) (results []BulkResult, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Bulk", bulkAttributes(ops, opts)...)
+	defer func() { tracing.End(span, err) }()
+
+	return s.next.Bulk(ctx, ops, deletedBy, opts)
+}
+
+func (s *tracedService) Import(ctx context.Context, file CSVImport, deletedBy string, dryRun bool) (report ImportReport, err error) {
+	ctx, span := tracing.Start(ctx, "products.Service.Import", attribute.Int("import.rows", len(file.Rows)), attribute.Bool("import.dry_run", dryRun))
+	defer func() {
+		span.SetAttributes(attribute.Int("import.failed", report.Failed), attribute.Bool("import.applied", report.Applied))
+		tracing.End(span, err)
+	}()
+
+	return s.next.Import(ctx, file, deletedBy, dryRun)
+}
+
+type tracedRepository struct {
+	next Repository
+}
+
+// NewTracedRepository devuelve un Repository que crea un span por cada método de r.
+func NewTracedRepository(r Repository) Repository {
+	return &tracedRepository{next: r}
+}
+
+func (r *tracedRepository) GetAll(ctx context.Context) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.GetAll")
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.GetAll(ctx)
+}
+
+func (r *tracedRepository) Count(ctx context.Context) (active, inactive int, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.Count")
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.Count(ctx)
+}
+
+func (r *tracedRepository) GetByID(ctx context.Context, id int) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.GetByID", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *tracedRepository) GetByCode(ctx context.Context, code string) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.GetByCode", productCode(code))
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.GetByCode(ctx, code)
+}
+
+func (r *tracedRepository) Store(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.Store", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.Store(ctx, id, name, color, price, stock, code, published, creationDate, active)
+}
+
+func (r *tracedRepository) LastID(ctx context.Context) (id int, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.LastID")
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.LastID(ctx)
+}
+
+func (r *tracedRepository) NextID(ctx context.Context) (id int, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.NextID")
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.NextID(ctx)
+}
+
+func (r *tracedRepository) Update(ctx context.Context, id int, name, color string, price float64, stock int, code string, published bool, creationDate string, active bool, deletedAt time.Time, deletedBy string, version int) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.Update", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.Update(ctx, id, name, color, price, stock, code, published, creationDate, active, deletedAt, deletedBy, version)
+}
+
+func (r *tracedRepository) HardDelete(ctx context.Context, id int, version int) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.HardDelete", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.HardDelete(ctx, id, version)
+}
+
+func (r *tracedRepository) Delete(ctx context.Context, id int, deletedAt time.Time, deletedBy string, version int) (products []domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.Delete", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.Delete(ctx, id, deletedAt, deletedBy, version)
+}
+
+func (r *tracedRepository) Restore(ctx context.Context, id int, version int) (product domain.Product, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.Restore", productID(id))
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.Restore(ctx, id, version)
+}
+
+func (r *tracedRepository) Bulk(ctx context.Context, ops []BulkOperation, opts BulkOptions) (results []BulkResult, err error) {
+	ctx, span := tracing.Start(ctx, "products.Repository.Bulk", bulkAttributes(ops, opts)...)
+	defer func() { tracing.End(span, err) }()
+
+	return r.next.Bulk(ctx, ops, opts)
+}
@@ -0,0 +1,169 @@
+// Package apperrors define los errores tipados que cruzan las capas de la API.
+// Cada error lleva un Kind que la capa web traduce a un código HTTP, de modo que
+// repositorios y servicios no necesitan conocer nada de HTTP, y un Code que identifica
+// el mensaje en el catálogo de pkg/i18n.
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/palomavs/go-web-II/pkg/i18n"
+)
+
+type Kind string
+
+const (
+	KindNotFound     Kind = "not_found"
+	KindValidation   Kind = "validation"
+	KindConflict     Kind = "conflict"
+	KindUnavailable  Kind = "storage_unavailable"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindTimeout      Kind = "timeout"
+	KindCanceled     Kind = "canceled"
+	// KindPreconditionFailed indica que el recurso cambió desde la versión que el cliente dice modificar.
+	KindPreconditionFailed Kind = "precondition_failed"
+	// KindUnsupportedMediaType indica que el cuerpo viene en un formato que la operación no acepta.
+	KindUnsupportedMediaType Kind = "unsupported_media_type"
+)
+
+type Error struct {
+	Kind Kind
+	// Code es la clave del mensaje en el catálogo y Args sus argumentos.
+	Code string
+	Args []interface{}
+	Err  error
+	// Fields detalla, para errores de validación, cada campo rechazado.
+	Fields []FieldError
+}
+
+// FieldError es una violación puntual sobre un campo de la petición.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Param es el argumento de la regla (el mínimo, el formato...), usado al traducir Message.
+	Param string `json:"-"`
+}
+
+// Error devuelve el mensaje en el idioma por defecto.
+func (e *Error) Error() string {
+	return e.Localize(i18n.Default.DefaultLocale())
+}
+
+// Localize devuelve el mensaje en locale. Los errores de validación enumeran sus campos.
+func (e *Error) Localize(locale string) string {
+	if len(e.Fields) == 0 {
+		return i18n.Default.Message(locale, e.Code, e.Args...)
+	}
+
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.LocalizeFields(locale) {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// LocalizeFields devuelve una copia de Fields con los mensajes en locale.
+func (e *Error) LocalizeFields(locale string) []FieldError {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+
+	fields := make([]FieldError, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field
+		if field.Param == "" {
+			fields[i].Message = i18n.Default.Message(locale, "field."+field.Code)
+		} else {
+			fields[i].Message = i18n.Default.Message(locale, "field."+field.Code, field.Param)
+		}
+	}
+	return fields
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(code string, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Args: args}
+}
+
+func Validation(code string, args ...interface{}) *Error {
+	return &Error{Kind: KindValidation, Code: code, Args: args}
+}
+
+func Conflict(code string, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Code: code, Args: args}
+}
+
+func PreconditionFailed(code string, args ...interface{}) *Error {
+	return &Error{Kind: KindPreconditionFailed, Code: code, Args: args}
+}
+
+func UnsupportedMediaType(code string, args ...interface{}) *Error {
+	return &Error{Kind: KindUnsupportedMediaType, Code: code, Args: args}
+}
+
+func Unauthorized(code string, args ...interface{}) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Args: args}
+}
+
+func Forbidden(code string, args ...interface{}) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Args: args}
+}
+
+// Unavailable envuelve una falla del almacenamiento. Si err ya es un *Error se devuelve tal cual,
+// para no reclasificar un error que otra capa ya tipó, y si se debe al vencimiento o la
+// cancelación del contexto de la petición se informa como tal y no como una falla del almacenamiento.
+func Unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Kind: KindTimeout, Code: "request_timeout", Err: err}
+	case errors.Is(err, context.Canceled):
+		return &Error{Kind: KindCanceled, Code: "request_canceled", Err: err}
+	}
+	return &Error{Kind: KindUnavailable, Code: "storage_unavailable", Err: err}
+}
+
+// KindOf devuelve el Kind del primer *Error de la cadena, o "" si err no es un error tipado.
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+	return ""
+}
+
+// FieldsOf devuelve las violaciones por campo del primer *Error de la cadena.
+func FieldsOf(err error) []FieldError {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+	return nil
+}
+
+// Localize devuelve el mensaje de err en locale y sus violaciones por campo traducidas.
+// Los errores no tipados se devuelven tal cual, sin campos.
+func Localize(err error, locale string) (string, []FieldError) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Localize(locale), appErr.LocalizeFields(locale)
+	}
+	return err.Error(), nil
+}
+
+func IsNotFound(err error) bool {
+	return KindOf(err) == KindNotFound
+}
@@ -0,0 +1,63 @@
+// Package audit registra los eventos de seguridad que deben poder revisarse después, como los
+// intentos de acceso rechazados. Cada evento se escribe como una línea JSON.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Acciones registradas.
+const (
+	ActionAccessDenied = "access_denied"
+)
+
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Subject    string    `json:"subject,omitempty"`
+	Roles      []string  `json:"roles,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Permission string    `json:"permission,omitempty"`
+	// Reason es el código del error con el que se rechazó la petición.
+	Reason     string `json:"reason"`
+	RemoteAddr string `json:"remoteAddr"`
+}
+
+type Logger interface {
+	Record(e Entry)
+}
+
+type jsonLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// NewLogger devuelve un Logger que escribe cada entrada como una línea JSON en w.
+func NewLogger(w io.Writer) Logger {
+	return &jsonLogger{w: w, now: time.Now}
+}
+
+func (l *jsonLogger) Record(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = l.now().UTC()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("error al intentar serializar la entrada de auditoría: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Un evento de auditoría perdido no debe cortar la petición, pero sí quedar en el log
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		log.Printf("error al intentar escribir la entrada de auditoría: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Package health expone los endpoints que consulta el orquestador: liveness indica que el
+// proceso responde y readiness que sus dependencias están accesibles y que no se está apagando.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/pkg/logging"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusShuttingDown es el estado de readiness durante el apagado ordenado.
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check comprueba una dependencia; devuelve nil si está disponible.
+type Check func(ctx context.Context) error
+
+// CheckResult no incluye el error de la dependencia, que puede revelar rutas o detalles internos
+// a quien consulte readiness; el error se registra en el log.
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// LatencyMs es lo que tardó la comprobación, en milisegundos.
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+type Checker struct {
+	timeout      time.Duration
+	names        []string
+	checks       map[string]Check
+	shuttingDown int32
+}
+
+// NewChecker crea un Checker en el que cada comprobación tiene como máximo timeout para responder.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Add registra una dependencia. No es seguro llamarlo mientras se atienden peticiones.
+func (c *Checker) Add(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// ShuttingDown hace que readiness falle de acá en adelante, para que el orquestador deje de
+// enviar tráfico mientras se drenan las peticiones en curso.
+func (c *Checker) ShuttingDown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+}
+
+// Run ejecuta todas las comprobaciones en paralelo.
+func (c *Checker) Run(ctx context.Context) Report {
+	if atomic.LoadInt32(&c.shuttingDown) == 1 {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	results := make([]CheckResult, len(c.names))
+	var wg sync.WaitGroup
+	for i, name := range c.names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = c.run(ctx, name, c.checks[name])
+		}(i, name)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, r := range results {
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, name string, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{Name: name, Status: StatusOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFail
+		logging.FromContext(ctx).Warn().Err(err).Str("check", name).Msg("health: la dependencia no está disponible")
+	}
+	return result
+}
+
+// Liveness responde siempre 200 mientras el proceso pueda atender peticiones, también durante
+// el apagado: reiniciar el proceso en ese momento solo cortaría las peticiones en curso.
+func (c *Checker) Liveness() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+// Readiness responde 200 si todas las dependencias están disponibles y 503 si alguna falla o
+// si el servidor se está apagando.
+func (c *Checker) Readiness() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		report := c.Run(ctx.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, report)
+	}
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func startServer(c *Checker) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", c.Liveness())
+	r.GET("/readyz", c.Readiness())
+	return r
+}
+
+func get(r *gin.Engine, path string) (int, Report) {
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report Report
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	return rr.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	var storeErr error
+	c := NewChecker(50 * time.Millisecond)
+	c.Add("products", func(ctx context.Context) error { return storeErr })
+	c.Add("lento", func(ctx context.Context) error { <-ctx.Done(); return nil })
+	c.Add("lento", func(ctx context.Context) error { return nil })
+	r := startServer(c)
+
+	status, report := get(r, "/readyz")
+	assert.Equal(t, http.StatusOK, status, "deben ser iguales")
+	assert.Equal(t, StatusOK, report.Status, "deben ser iguales")
+	assert.Len(t, report.Checks, 2, "un nombre repetido reemplaza a la comprobación anterior")
+
+	storeErr = errors.New("invalid character 'x' looking for beginning of value")
+	status, report = get(r, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status, "deben ser iguales")
+	assert.Equal(t, StatusFail, report.Status, "deben ser iguales")
+	assert.Equal(t, CheckResult{Name: "products", Status: StatusFail, LatencyMs: report.Checks[0].LatencyMs}, report.Checks[0], "deben ser iguales")
+	assert.Equal(t, StatusOK, report.Checks[1].Status, "deben ser iguales")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.NotContains(t, rr.Body.String(), "invalid character", "el error no debe llegar a la respuesta")
+}
+
+func TestReadiness_Timeout(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	c.Add("colgado", func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() })
+
+	status, report := get(startServer(c), "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status, "deben ser iguales")
+	assert.GreaterOrEqual(t, report.Checks[0].LatencyMs, float64(20), "la latencia debe reflejar la espera")
+}
+
+func TestShuttingDown(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("products", func(ctx context.Context) error { return nil })
+	r := startServer(c)
+	c.ShuttingDown()
+
+	status, report := get(r, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status, "deben ser iguales")
+	assert.Equal(t, StatusShuttingDown, report.Status, "deben ser iguales")
+
+	status, _ = get(r, "/healthz")
+	assert.Equal(t, http.StatusOK, status, "liveness no debe fallar durante el apagado")
+}
@@ -0,0 +1,120 @@
+// Package i18n resuelve los mensajes de la API a partir de un código y un idioma.
+// Los catálogos viven en locales/<idioma>.json y se embeben en el binario.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+type Catalog struct {
+	mu            sync.RWMutex
+	messages      map[string]map[string]string
+	defaultLocale string
+}
+
+// Default es el catálogo con los idiomas embebidos; su idioma por defecto es español.
+var Default = mustLoad("es")
+
+func mustLoad(defaultLocale string) *Catalog {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	c := &Catalog{messages: map[string]map[string]string{}, defaultLocale: defaultLocale}
+	for _, entry := range entries {
+		raw, err := locales.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", entry.Name(), err))
+		}
+		c.messages[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+	return c
+}
+
+// SetDefaultLocale cambia el idioma usado cuando el cliente no pide uno soportado.
+func (c *Catalog) SetDefaultLocale(locale string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.messages[locale]; !ok {
+		return fmt.Errorf("i18n: idioma no soportado %q", locale)
+	}
+	c.defaultLocale = locale
+	return nil
+}
+
+func (c *Catalog) DefaultLocale() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.defaultLocale
+}
+
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Message formatea el mensaje code en locale. Si el idioma no tiene el código se usa el idioma
+// por defecto, y si tampoco existe se devuelve el código, para que nunca se pierda la información.
+func (c *Catalog) Message(locale, code string, args ...interface{}) string {
+	format, ok := c.messages[locale][code]
+	if !ok {
+		format, ok = c.messages[c.DefaultLocale()][code]
+	}
+	if !ok {
+		return code
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Negotiate elige, según un header Accept-Language, el idioma soportado de mayor preferencia.
+// Se compara por idioma base, así "en-US" usa el catálogo "en".
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		base := strings.SplitN(tag, "-", 2)[0]
+		if _, ok := c.messages[base]; ok && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+
+	if best == "" {
+		return c.DefaultLocale()
+	}
+	return best
+}
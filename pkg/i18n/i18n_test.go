@@ -0,0 +1,48 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	c := mustLoad("es")
+
+	cases := map[string]string{
+		"":                           "es",
+		"fr-FR":                      "es",
+		"en":                         "en",
+		"en-US,en;q=0.9":             "en",
+		"fr;q=1, es;q=0.5, en;q=0.8": "en",
+		"es-AR, en;q=0.5":            "es",
+	}
+	for header, expected := range cases {
+		assert.Equal(t, expected, c.Negotiate(header), header)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	c := mustLoad("es")
+
+	assert.Equal(t, "producto de id 2 no encontrado", c.Message("es", "product_not_found", 2))
+	assert.Equal(t, "product with id 2 not found", c.Message("en", "product_not_found", 2))
+	assert.Equal(t, "producto de id 2 no encontrado", c.Message("fr", "product_not_found", 2))
+	assert.Equal(t, "codigo_inexistente", c.Message("en", "codigo_inexistente"))
+
+	assert.Nil(t, c.SetDefaultLocale("en"))
+	assert.Equal(t, "en", c.Negotiate("fr"))
+	assert.NotNil(t, c.SetDefaultLocale("fr"))
+}
+
+// Todos los catálogos deben definir los mismos códigos que el idioma por defecto.
+func TestCatalogsComplete(t *testing.T) {
+	c := mustLoad("es")
+
+	for _, locale := range c.Locales() {
+		for code := range c.messages["es"] {
+			_, ok := c.messages[locale][code]
+			assert.True(t, ok, "falta %s en %s", code, locale)
+		}
+	}
+}
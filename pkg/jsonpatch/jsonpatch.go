@@ -0,0 +1,346 @@
+// Package jsonpatch aplica cambios parciales sobre documentos JSON con los dos formatos
+// estándar: JSON Merge Patch (RFC 7396) y JSON Patch (RFC 6902).
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrTestFailed indica que una operación test no se cumplió; el documento no cambia.
+var ErrTestFailed = errors.New("jsonpatch: la operación test no se cumplió")
+
+// MergePatch es un documento JSON Merge Patch: los miembros presentes reemplazan a los del
+// documento, null borra el miembro y los objetos se combinan recursivamente.
+type MergePatch []byte
+
+// NewMergePatch controla que data sea JSON válido.
+func NewMergePatch(data []byte) (MergePatch, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("el merge patch no es JSON válido")
+	}
+	return MergePatch(data), nil
+}
+
+func (p MergePatch) Apply(doc []byte) ([]byte, error) {
+	var target, patch interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(p, &patch); err != nil {
+		return nil, err
+	}
+	return json.Marshal(merge(target, patch))
+}
+
+func merge(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(object, name)
+		} else {
+			object[name] = merge(object[name], value)
+		}
+	}
+	return object
+}
+
+// Operation es una operación de JSON Patch.
+type Operation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	// Value queda en nil si la operación no lo trae, para distinguirlo de un null explícito.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch es un documento JSON Patch: una lista de operaciones que se aplican en orden y de forma
+// atómica.
+type Patch []Operation
+
+// Decode lee un documento JSON Patch y controla que cada operación tenga los miembros que requiere.
+func Decode(data []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, fmt.Errorf("el JSON patch debe ser una lista de operaciones: %w", err)
+	}
+
+	for i, op := range patch {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operación %d (%s): falta value", i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("operación %d (%s): %w", i, op.Op, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operación %d: op %q desconocida", i, op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("operación %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return patch, nil
+}
+
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	for i, op := range p {
+		var err error
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("operación %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func (op Operation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && isPrefix(from, path) {
+			return nil, errors.New("no se puede mover un valor dentro de sí mismo")
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "test":
+		expected, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("op %q desconocida", op.Op)
+}
+
+func (op Operation) value() (interface{}, error) {
+	if op.Value == nil {
+		return nil, errors.New("falta value")
+	}
+	var value interface{}
+	err := json.Unmarshal(op.Value, &value)
+	return value, err
+}
+
+// parsePointer separa un JSON Pointer (RFC 6901) en sus claves; "" es el documento completo.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("la ruta %q debe empezar con /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, token := range tokens {
+		tokens[i] = unescape.Replace(token)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		var err error
+		if doc, err = child(doc, token); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func child(doc interface{}, token string) (interface{}, error) {
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		value, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("no existe el miembro %q", token)
+		}
+		return value, nil
+	case []interface{}:
+		i, err := index(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		return container[i], nil
+	}
+	return nil, fmt.Errorf("no se puede acceder a %q en un valor que no es objeto ni lista", token)
+}
+
+// index interpreta token como posición de una lista, entre 0 y max.
+func index(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%q no es una posición de lista válida", token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("la posición %d está fuera de la lista", i)
+	}
+	return i, nil
+}
+
+// update recorre doc hasta el contenedor que aloja la última clave de path y lo reemplaza por
+// el resultado de fn.
+func update(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	next, err := child(doc, path[0])
+	if err != nil {
+		return nil, err
+	}
+	if next, err = update(next, path[1:], fn); err != nil {
+		return nil, err
+	}
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		container[path[0]] = next
+	case []interface{}:
+		i, _ := index(path[0], len(container)-1)
+		container[i] = next
+	}
+	return doc, nil
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			container[token] = value
+			return container, nil
+		case []interface{}:
+			// Para agregar, "-" y len(container) apuntan al final de la lista
+			i := len(container)
+			if token != "-" {
+				var err error
+				if i, err = index(token, len(container)); err != nil {
+					return nil, err
+				}
+			}
+			container = append(container, nil)
+			copy(container[i+1:], container[i:])
+			container[i] = value
+			return container, nil
+		}
+		return nil, fmt.Errorf("no se puede agregar %q a un valor que no es objeto ni lista", token)
+	})
+}
+
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	return update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := container[token]; !ok {
+				return nil, fmt.Errorf("no existe el miembro %q", token)
+			}
+			delete(container, token)
+			return container, nil
+		case []interface{}:
+			i, err := index(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(container[:i], container[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("no se puede quitar %q de un valor que no es objeto ni lista", token)
+	})
+}
+
+func deepCopy(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied interface{}
+	err = json.Unmarshal(data, &copied)
+	return copied, err
+}
@@ -0,0 +1,92 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Ejemplos del apéndice A de RFC 7396
+	cases := []struct{ doc, patch, expected string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+	}
+
+	for _, c := range cases {
+		patch, err := NewMergePatch([]byte(c.patch))
+		assert.Nil(t, err, "no debería dar error")
+		result, err := patch.Apply([]byte(c.doc))
+		assert.Nil(t, err, "no debería dar error")
+		assert.JSONEq(t, c.expected, string(result), c.patch)
+	}
+
+	_, err := NewMergePatch([]byte(`{"a":`))
+	assert.NotNil(t, err, "debería dar error")
+}
+
+func TestPatch(t *testing.T) {
+	// Ejemplos del apéndice A de RFC 6902
+	cases := []struct{ doc, patch, expected string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+		{`{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+
+	for _, c := range cases {
+		patch, err := Decode([]byte(c.patch))
+		assert.Nil(t, err, "no debería dar error")
+		result, err := patch.Apply([]byte(c.doc))
+		assert.Nil(t, err, "no debería dar error")
+		assert.JSONEq(t, c.expected, string(result), c.patch)
+	}
+}
+
+func TestPatch_Errors(t *testing.T) {
+	for _, data := range []string{
+		`{"op":"add"}`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"rename","path":"/a"}]`,
+		`[{"op":"remove","path":"a"}]`,
+		`[{"op":"move","from":"a","path":"/b"}]`,
+	} {
+		_, err := Decode([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+
+	for _, data := range []string{
+		`[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+		`[{"op":"remove","path":"/nope"}]`,
+		`[{"op":"replace","path":"/list/5","value":1}]`,
+		`[{"op":"add","path":"/list/01","value":1}]`,
+		`[{"op":"remove","path":"/list/-"}]`,
+		`[{"op":"move","from":"/obj","path":"/obj/inner"}]`,
+	} {
+		patch, err := Decode([]byte(data))
+		assert.Nil(t, err, "no debería dar error")
+		_, err = patch.Apply([]byte(`{"foo":"bar","list":[1,2],"obj":{}}`))
+		assert.NotNil(t, err, data)
+	}
+
+	patch, err := Decode([]byte(`[{"op":"replace","path":"/foo","value":"baz"},{"op":"test","path":"/foo","value":"bar"}]`))
+	assert.Nil(t, err, "no debería dar error")
+	_, err = patch.Apply([]byte(`{"foo":"bar"}`))
+	assert.True(t, errors.Is(err, ErrTestFailed), "debería fallar el test")
+}
@@ -0,0 +1,205 @@
+package jwtauth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// minSecretLength es el largo mínimo de un secreto HS256: 256 bits, igual que el hash.
+const minSecretLength = 32
+
+// Key es una clave de verificación. Public es un []byte para HS256, un *rsa.PublicKey para
+// RS256 o un *ecdsa.PublicKey de la curva P-256 para ES256.
+type Key struct {
+	// ID se compara con el header kid del token; si está vacío la clave sirve para cualquier kid.
+	ID string
+	// Algorithm restringe la clave a un algoritmo; si está vacío se deduce del tipo de Public.
+	Algorithm string
+	Public    interface{}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// LoadFile lee las claves de un archivo local. Según su contenido se interpreta como un
+// JWKS (JSON), como claves públicas o certificados PEM, o como un secreto HS256 en crudo.
+func LoadFile(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	var keys []Key
+	switch {
+	case bytes.HasPrefix(data, []byte("{")):
+		keys, err = parseJWKS(data)
+	case bytes.HasPrefix(data, []byte("-----BEGIN")):
+		keys, err = parsePEM(data)
+	default:
+		keys, err = parseSecret(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+func parseSecret(data []byte) ([]Key, error) {
+	if len(data) < minSecretLength {
+		return nil, fmt.Errorf("el secreto HS256 debe tener al menos %d bytes", minSecretLength)
+	}
+	return []Key{{Algorithm: "HS256", Public: data}}, nil
+}
+
+func parsePEM(data []byte) ([]Key, error) {
+	var keys []Key
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var public interface{}
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				public = cert.PublicKey
+			}
+		default:
+			return nil, fmt.Errorf("bloque PEM %q no soportado", block.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if algorithmOf(public) == "" {
+			return nil, fmt.Errorf("tipo de clave %T no soportado", public)
+		}
+		keys = append(keys, Key{Public: public})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("el archivo no tiene bloques PEM")
+	}
+	return keys, nil
+}
+
+func parseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []Key
+	for _, k := range set.Keys {
+		// Las claves de cifrado no sirven para verificar firmas
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		public, err := k.public()
+		if err != nil {
+			return nil, fmt.Errorf("clave %q: %w", k.Kid, err)
+		}
+		keys = append(keys, Key{ID: k.Kid, Algorithm: k.Alg, Public: public})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("el JWKS no tiene claves de firma")
+	}
+	return keys, nil
+}
+
+func (k jwk) public() (interface{}, error) {
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) < minSecretLength {
+			return nil, fmt.Errorf("el secreto HS256 debe tener al menos %d bytes", minSecretLength)
+		}
+		return secret, nil
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("curva %q no soportada", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("el punto no pertenece a la curva")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("tipo de clave %q no soportado", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// algorithmOf devuelve el único algoritmo aceptado para el tipo de clave, o "" si no se soporta.
+func algorithmOf(public interface{}) string {
+	switch k := public.(type) {
+	case []byte:
+		return "HS256"
+	case *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return "ES256"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+// Package jwtauth verifica tokens JWT firmados por un emisor externo (por ejemplo el SSO) con
+// claves configuradas localmente. Solo se aceptan HS256, RS256 y ES256.
+package jwtauth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+var algorithms = []string{"HS256", "RS256", "ES256"}
+
+// Claims son los claims que la API usa de un token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	// Scope es la lista de scopes separados por espacios, como en OAuth 2.0.
+	Scope string `json:"scope,omitempty"`
+}
+
+// Scopes devuelve los scopes del claim scope.
+func (c Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+type Verifier struct {
+	keys     []Key
+	audience string
+	issuer   string
+	// Leeway es la tolerancia de reloj al evaluar exp y nbf.
+	Leeway time.Duration
+	now    func() time.Time
+}
+
+// NewVerifier crea un verificador. Si audience o issuer no son vacíos, el token debe tenerlos
+// en aud e iss respectivamente.
+func NewVerifier(keys []Key, audience, issuer string) *Verifier {
+	return &Verifier{keys: keys, audience: audience, issuer: issuer, Leeway: 30 * time.Second, now: time.Now}
+}
+
+// Verify comprueba la firma y los claims exp, nbf, aud e iss del token. Los errores son
+// siempre de tipo unauthorized.
+func (v *Verifier) Verify(raw string) (*Claims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(algorithms), jwt.WithoutClaimsValidation())
+
+	unverified, _, err := parser.ParseUnverified(raw, &Claims{})
+	if err != nil {
+		return nil, invalid(err)
+	}
+	kid, _ := unverified.Header["kid"].(string)
+	candidates := v.candidates(unverified.Method.Alg(), kid)
+	if len(candidates) == 0 {
+		return nil, invalid(errors.New("no hay una clave para el algoritmo y kid del token"))
+	}
+
+	// Sin kid puede haber más de una clave posible, por ejemplo durante una rotación
+	for _, key := range candidates {
+		claims := &Claims{}
+		_, err = parser.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+			return key.Public, nil
+		})
+		if err == nil {
+			return claims, v.validate(claims)
+		}
+	}
+	return nil, invalid(err)
+}
+
+func (v *Verifier) candidates(alg, kid string) []Key {
+	var keys []Key
+	for _, k := range v.keys {
+		if kid != "" && k.ID != "" && k.ID != kid {
+			continue
+		}
+		if k.Algorithm != "" && k.Algorithm != alg {
+			continue
+		}
+		if algorithmOf(k.Public) != alg {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	now := v.now()
+
+	if c.ExpiresAt == nil {
+		return invalid(errors.New("el token no tiene exp"))
+	}
+	if now.After(c.ExpiresAt.Add(v.Leeway)) {
+		return apperrors.Unauthorized("token_expired")
+	}
+	if c.NotBefore != nil && now.Add(v.Leeway).Before(c.NotBefore.Time) {
+		return invalid(errors.New("el token todavía no es válido"))
+	}
+	if v.audience != "" && !c.VerifyAudience(v.audience, true) {
+		return invalid(errors.New("aud no corresponde a esta API"))
+	}
+	if v.issuer != "" && !c.VerifyIssuer(v.issuer, true) {
+		return invalid(errors.New("iss no es el emisor configurado"))
+	}
+	if c.Subject == "" {
+		return invalid(errors.New("el token no tiene sub"))
+	}
+	return nil
+}
+
+func invalid(err error) error {
+	e := apperrors.Unauthorized("invalid_token")
+	e.Err = err
+	return e
+}
@@ -0,0 +1,154 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/stretchr/testify/assert"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+func claims(now time.Time) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "ana",
+			Issuer:    "https://sso.example.com",
+			Audience:  jwt.ClaimStrings{"products-api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		Roles: []string{"editor"},
+		Scope: "products:read products:write",
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, c Claims) string {
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	assert.Nil(t, err, "no debería dar error")
+	return raw
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestVerify_HS256(t *testing.T) {
+	keys, err := LoadFile(writeFile(t, "secret", append(secret, '\n')))
+	assert.Nil(t, err, "no debería dar error")
+	v := NewVerifier(keys, "products-api", "https://sso.example.com")
+
+	got, err := v.Verify(sign(t, jwt.SigningMethodHS256, secret, "", claims(time.Now())))
+	assert.Nil(t, err, "no debería dar error")
+	assert.Equal(t, "ana", got.Subject, "deben ser iguales")
+	assert.Equal(t, []string{"editor"}, got.Roles, "deben ser iguales")
+	assert.Equal(t, []string{"products:read", "products:write"}, got.Scopes(), "deben ser iguales")
+}
+
+func TestVerify_RS256FromJWKS(t *testing.T) {
+	old, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	current, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "2021", "alg": "RS256", "n": b64(old.N), "e": b64(big.NewInt(int64(old.E)))},
+		{"kty": "RSA", "kid": "2022", "alg": "RS256", "n": b64(current.N), "e": b64(big.NewInt(int64(current.E)))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(current.N), "e": b64(big.NewInt(int64(current.E)))},
+	}})
+	keys, err := LoadFile(writeFile(t, "jwks.json", jwks))
+	assert.Nil(t, err, "no debería dar error")
+	assert.Len(t, keys, 2, "las claves de cifrado se ignoran")
+	v := NewVerifier(keys, "products-api", "")
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, current, "2022", claims(time.Now())))
+	assert.Nil(t, err, "no debería dar error")
+
+	// Sin kid se prueban todas las claves RSA
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, current, "", claims(time.Now())))
+	assert.Nil(t, err, "no debería dar error")
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, current, "2021", claims(time.Now())))
+	assert.Equal(t, apperrors.KindUnauthorized, apperrors.KindOf(err), "deben ser iguales")
+}
+
+func TestVerify_ES256FromPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+
+	keys, err := LoadFile(writeFile(t, "sso.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.Nil(t, err, "no debería dar error")
+	v := NewVerifier(keys, "", "")
+
+	_, err = v.Verify(sign(t, jwt.SigningMethodES256, key, "", claims(time.Now())))
+	assert.Nil(t, err, "no debería dar error")
+
+	// Una clave pública no puede usarse como secreto HS256
+	_, err = v.Verify(sign(t, jwt.SigningMethodHS256, der, "", claims(time.Now())))
+	assert.Equal(t, apperrors.KindUnauthorized, apperrors.KindOf(err), "deben ser iguales")
+}
+
+func TestVerify_Claims(t *testing.T) {
+	v := NewVerifier([]Key{{Public: secret}}, "products-api", "https://sso.example.com")
+	now := time.Now()
+
+	expired := claims(now)
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour))
+	notYet := claims(now)
+	notYet.NotBefore = jwt.NewNumericDate(now.Add(time.Hour))
+	otherAudience := claims(now)
+	otherAudience.Audience = jwt.ClaimStrings{"billing-api"}
+	otherIssuer := claims(now)
+	otherIssuer.Issuer = "https://evil.example.com"
+	withoutExp := claims(now)
+	withoutExp.ExpiresAt = nil
+
+	cases := map[string]struct {
+		claims Claims
+		code   string
+	}{
+		"expirado":      {expired, "token_expired"},
+		"nbf futuro":    {notYet, "invalid_token"},
+		"otra audience": {otherAudience, "invalid_token"},
+		"otro emisor":   {otherIssuer, "invalid_token"},
+		"sin exp":       {withoutExp, "invalid_token"},
+	}
+	for name, c := range cases {
+		_, err := v.Verify(sign(t, jwt.SigningMethodHS256, secret, "", c.claims))
+		var appErr *apperrors.Error
+		assert.ErrorAs(t, err, &appErr, name)
+		assert.Equal(t, c.code, appErr.Code, name)
+	}
+
+	_, err := v.Verify("no.es.un.jwt")
+	assert.Equal(t, apperrors.KindUnauthorized, apperrors.KindOf(err), "deben ser iguales")
+}
+
+func TestLoadFile_ShortSecret(t *testing.T) {
+	_, err := LoadFile(writeFile(t, "secret", []byte("corto")))
+	assert.NotNil(t, err, "debería dar error")
+}
@@ -0,0 +1,125 @@
+// Package logging configura los logs estructurados en JSON y los asocia a cada petición: el
+// middleware genera o propaga el X-Request-ID y deja en el contexto un logger con ese id, que
+// servicios y repositorios obtienen con FromContext.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// RequestIDHeader es el header por el que se recibe y se devuelve el id de la petición.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limita el id recibido para que un cliente no pueda inflar los logs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// New crea un logger JSON con timestamp en w, en el nivel indicado (debug, info, warn o error),
+// lo deja como logger por defecto de los contextos sin logger y redirige el paquete log a él.
+func New(w io.Writer, level string) (zerolog.Logger, error) {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return zerolog.Logger{}, err
+	}
+
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	logger := zerolog.New(w).Level(lvl).With().Timestamp().Logger()
+	zerolog.DefaultContextLogger = &logger
+
+	log.SetFlags(0)
+	log.SetOutput(logger)
+	return logger, nil
+}
+
+// FromContext devuelve el logger de la petición o, fuera de una petición, el logger por defecto.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	return zerolog.Ctx(ctx)
+}
+
+// RequestID devuelve el id de la petición de ctx, o "" si no hay.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// AddFields agrega campos al logger de la petición, de modo que aparezcan en todas las líneas
+// que se escriban después, incluida la del access log.
+func AddFields(ctx context.Context, fields map[string]interface{}) {
+	FromContext(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Fields(fields)
+	})
+}
+
+// Middleware registra una línea por petición con método, ruta, código, latencia, cliente y bytes.
+func Middleware(base zerolog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		ctx.Header(RequestIDHeader, id)
+
+		// Cada petición tiene su propia copia, así AddFields no afecta a las demás
+		logger := base.With().Str("requestId", id).Logger()
+		c := context.WithValue(ctx.Request.Context(), requestIDKey{}, id)
+		ctx.Request = ctx.Request.WithContext(logger.WithContext(c))
+
+		ctx.Next()
+
+		// WithContext guarda una copia: hay que usar esa para ver los campos agregados con AddFields
+		logger = *FromContext(ctx.Request.Context())
+
+		status := ctx.Writer.Status()
+		event := logger.Info()
+		switch {
+		case status >= 500:
+			event = logger.Error()
+		case status >= 400:
+			event = logger.Warn()
+		}
+		event.
+			Str("method", ctx.Request.Method).
+			Str("route", ctx.FullPath()).
+			Str("path", ctx.Request.URL.Path).
+			Int("status", status).
+			Float64("latencyMs", float64(time.Since(start).Microseconds())/1000).
+			Str("client", ctx.ClientIP()).
+			Int("bytes", bytesWritten(ctx)).
+			Msg("request")
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool { return r < '!' || r > '~' }) == -1
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// bytesWritten es el tamaño del cuerpo de la respuesta; gin informa -1 si no se escribió nada.
+func bytesWritten(ctx *gin.Context) int {
+	if size := ctx.Writer.Size(); size > 0 {
+		return size
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func startServer(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	gin.SetMode(gin.TestMode)
+	buf := &bytes.Buffer{}
+	logger, err := New(buf, "info")
+	assert.Nil(t, err, "no debería dar error")
+
+	r := gin.New()
+	r.Use(Middleware(logger))
+	r.GET("/products/:id", func(ctx *gin.Context) {
+		AddFields(ctx.Request.Context(), map[string]interface{}{"subject": "ana"})
+		FromContext(ctx.Request.Context()).Debug().Msg("no debería aparecer con nivel info")
+		FromContext(ctx.Request.Context()).Info().Msg("desde el servicio")
+		ctx.String(http.StatusNotFound, RequestID(ctx.Request.Context()))
+	})
+	return r, buf
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry), "cada línea debe ser JSON")
+		result = append(result, entry)
+	}
+	return result
+}
+
+func TestMiddleware(t *testing.T) {
+	r, buf := startServer(t)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/products/7", nil))
+
+	id := rr.Header().Get(RequestIDHeader)
+	assert.Len(t, id, 32, "debe generar un id")
+	assert.Equal(t, id, rr.Body.String(), "el id debe estar en el contexto")
+
+	entries := lines(t, buf)
+	assert.Len(t, entries, 2, "deben ser iguales")
+	assert.Equal(t, "desde el servicio", entries[0]["message"], "deben ser iguales")
+	assert.Equal(t, id, entries[0]["requestId"], "las líneas del servicio llevan el id")
+
+	access := entries[1]
+	assert.Equal(t, "warn", access["level"], "un 4xx se registra como warn")
+	assert.Equal(t, id, access["requestId"], "deben ser iguales")
+	assert.Equal(t, "ana", access["subject"], "los campos agregados llegan al access log")
+	assert.Equal(t, "GET", access["method"], "deben ser iguales")
+	assert.Equal(t, "/products/:id", access["route"], "deben ser iguales")
+	assert.Equal(t, "/products/7", access["path"], "deben ser iguales")
+	assert.Equal(t, float64(404), access["status"], "deben ser iguales")
+	assert.Equal(t, float64(32), access["bytes"], "deben ser iguales")
+	assert.Contains(t, access, "latencyMs")
+	assert.Contains(t, access, "client")
+}
+
+func TestMiddleware_PropagatesRequestID(t *testing.T) {
+	r, _ := startServer(t)
+
+	for header, propagated := range map[string]bool{
+		"abc-123":                    true,
+		"con espacios":               false,
+		strings.Repeat("x", 129):     false,
+		"f47ac10b-58cc-4372-a567-0e": true,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		req.Header.Set(RequestIDHeader, header)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, propagated, rr.Header().Get(RequestIDHeader) == header, header)
+	}
+}
+
+func TestNew_InvalidLevel(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose")
+	assert.NotNil(t, err, "debería dar error")
+}
@@ -0,0 +1,146 @@
+// Package metrics expone en formato de Prometheus las métricas HTTP, de almacenamiento y del
+// catálogo de productos.
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "products_api"
+
+// unmatchedRoute agrupa las peticiones que no corresponden a ninguna ruta, para que una URL
+// arbitraria no cree una serie nueva por cada valor.
+const unmatchedRoute = "unmatched"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	storeDuration   *prometheus.HistogramVec
+	storeErrors     *prometheus.CounterVec
+}
+
+// New crea las métricas en un registro propio, junto con las del runtime de Go y del proceso.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Peticiones HTTP atendidas, por método, ruta y código de respuesta.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duración de las peticiones HTTP, por método y ruta.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Peticiones HTTP en curso.",
+		}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Duración de las operaciones del almacenamiento, por store y operación.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"store", "operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_errors_total",
+			Help:      "Operaciones del almacenamiento que fallaron, por store y operación.",
+		}, []string{"store", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests, m.requestDuration, m.inFlight, m.storeDuration, m.storeErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Register agrega collectors adicionales, como el de estadísticas de la base o el de productos.
+func (m *Metrics) Register(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
+
+// Middleware mide cada petición. La ruta es el patrón de gin (/products/:id), no la URL, para
+// mantener acotada la cantidad de series.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := ctx.Request.Method
+		m.requests.WithLabelValues(method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		m.requestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler sirve las métricas en el formato de texto de Prometheus.
+func (m *Metrics) Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return gin.WrapH(h)
+}
+
+// ObserveStore registra la duración y el resultado de una operación del almacenamiento.
+func (m *Metrics) ObserveStore(store, operation string, d time.Duration, err error) {
+	m.storeDuration.WithLabelValues(store, operation).Observe(d.Seconds())
+	if err != nil {
+		m.storeErrors.WithLabelValues(store, operation).Inc()
+	}
+}
+
+// ProductCounter devuelve cuántos productos activos e inactivos hay.
+type ProductCounter func(ctx context.Context) (active, inactive int, err error)
+
+type productsCollector struct {
+	count   ProductCounter
+	timeout time.Duration
+	desc    *prometheus.Desc
+}
+
+// NewProductsCollector expone la cantidad de productos por estado. Se cuenta en cada scrape,
+// con timeout como máximo; si falla, la métrica no se publica en ese scrape.
+func NewProductsCollector(count ProductCounter, timeout time.Duration) prometheus.Collector {
+	return &productsCollector{
+		count:   count,
+		timeout: timeout,
+		desc:    prometheus.NewDesc(namespace+"_products", "Cantidad de productos, por estado (active o inactive).", []string{"state"}, nil),
+	}
+}
+
+func (c *productsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *productsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	active, inactive, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(active), "active")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(inactive), "inactive")
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/products/:id", func(ctx *gin.Context) { ctx.Status(http.StatusNotFound) })
+	r.GET("/metrics", m.Handler())
+
+	for _, path := range []string{"/products/1", "/products/2", "/no-existe"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("GET", "/products/:id", "404")), "deben ser iguales")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("GET", unmatchedRoute, "404")), "deben ser iguales")
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.inFlight), "no deben quedar peticiones en curso")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rr.Body.String(), `products_api_http_request_duration_seconds_count{method="GET",route="/products/:id"} 2`)
+}
+
+func TestInstrumentStore(t *testing.T) {
+	m := New()
+	mock := &store.Mock{Data: []byte(`[]`)}
+	s := m.InstrumentStore("products", &store.FileStore{Mock: mock})
+
+	var data []interface{}
+	assert.Nil(t, s.Read(context.Background(), &data), "no debería dar error")
+	mock.Err = errors.New("disco lleno")
+	assert.NotNil(t, s.Write(context.Background(), data), "debería dar error")
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.storeDuration), "una serie por operación")
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.storeErrors.WithLabelValues("products", "read")), "deben ser iguales")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.storeErrors.WithLabelValues("products", "write")), "deben ser iguales")
+}
+
+func TestProductsCollector(t *testing.T) {
+	c := NewProductsCollector(func(ctx context.Context) (int, int, error) { return 3, 1, nil }, time.Second)
+
+	expected := `
+# HELP products_api_products Cantidad de productos, por estado (active o inactive).
+# TYPE products_api_products gauge
+products_api_products{state="active"} 3
+products_api_products{state="inactive"} 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(c, strings.NewReader(expected)), "no debería dar error")
+}
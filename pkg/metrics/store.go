@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/palomavs/go-web-II/pkg/store"
+)
+
+type instrumentedStore struct {
+	store.Store
+	name    string
+	metrics *Metrics
+}
+
+// InstrumentStore devuelve un store.Store que mide cada operación de s con la etiqueta store=name.
+// El tiempo de Lock es el de espera por el lock, no el que se lo mantiene tomado.
+func (m *Metrics) InstrumentStore(name string, s store.Store) store.Store {
+	return &instrumentedStore{Store: s, name: name, metrics: m}
+}
+
+func (s *instrumentedStore) observe(operation string, start time.Time, err error) {
+	s.metrics.ObserveStore(s.name, operation, time.Since(start), err)
+}
+
+func (s *instrumentedStore) Read(ctx context.Context, data interface{}) (err error) {
+	start := time.Now()
+	defer func() { s.observe("read", start, err) }()
+
+	return s.Store.Read(ctx, data)
+}
+
+func (s *instrumentedStore) Write(ctx context.Context, data interface{}) (err error) {
+	start := time.Now()
+	defer func() { s.observe("write", start, err) }()
+
+	return s.Store.Write(ctx, data)
+}
+
+func (s *instrumentedStore) Lock(ctx context.Context) (unlock func(), err error) {
+	start := time.Now()
+	defer func() { s.observe("lock", start, err) }()
+
+	return s.Store.Lock(ctx)
+}
+
+func (s *instrumentedStore) Sequence(ctx context.Context, floor, n int) (first int, err error) {
+	start := time.Now()
+	defer func() { s.observe("sequence", start, err) }()
+
+	return s.Store.Sequence(ctx, floor, n)
+}
@@ -0,0 +1,147 @@
+// Package server envuelve un http.Server con timeouts configurables y un apagado ordenado:
+// al cancelarse el contexto de Run deja de aceptar conexiones, espera las peticiones en curso
+// y ejecuta los hooks de cierre, todo dentro del período de gracia.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ShutdownTimeout es el período de gracia para terminar las peticiones en curso y los hooks de cierre.
+	ShutdownTimeout time.Duration
+	// DrainDelay es cuánto se sigue aceptando conexiones después de la señal, para que el
+	// orquestador vea fallar readiness y deje de enviar tráfico antes de cerrar el listener.
+	DrainDelay time.Duration
+}
+
+// DefaultConfig son los valores que se usan para los campos no configurados.
+var DefaultConfig = Config{
+	Addr:              ":8080",
+	ReadTimeout:       15 * time.Second,
+	ReadHeaderTimeout: 5 * time.Second,
+	WriteTimeout:      30 * time.Second,
+	IdleTimeout:       60 * time.Second,
+	MaxHeaderBytes:    1 << 20,
+	ShutdownTimeout:   20 * time.Second,
+}
+
+type Server struct {
+	http            *http.Server
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	draining        []func()
+	hooks           []func(ctx context.Context) error
+}
+
+func New(handler http.Handler, cfg Config) *Server {
+	cfg = withDefaults(cfg)
+	return &Server{
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+		drainDelay:      cfg.DrainDelay,
+	}
+}
+
+// OnDraining registra f para ejecutarse apenas empieza el apagado, antes del DrainDelay.
+func (s *Server) OnDraining(f func()) {
+	s.draining = append(s.draining, f)
+}
+
+// OnShutdown registra f para ejecutarse una vez drenadas las peticiones, por ejemplo para
+// esperar escrituras pendientes o vaciar buffers. Los hooks corren en orden inverso al de
+// registro, como los defer.
+func (s *Server) OnShutdown(f func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, f)
+}
+
+// Run atiende peticiones hasta que se cancela ctx y entonces apaga el servidor. Devuelve el
+// error que impidió escuchar o el primero que ocurrió durante el apagado.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve es como Run pero sobre un listener ya abierto.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.http.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	for _, f := range s.draining {
+		f()
+	}
+	if s.drainDelay > 0 {
+		log.Printf("apagando el servidor, se siguen aceptando conexiones durante %s", s.drainDelay)
+		time.Sleep(s.drainDelay)
+	}
+
+	log.Printf("apagando el servidor, esperando hasta %s a las peticiones en curso", s.shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	err := s.http.Shutdown(shutdownCtx)
+	for i := len(s.hooks) - 1; i >= 0; i-- {
+		if errHook := s.hooks[i](shutdownCtx); errHook != nil && err == nil {
+			err = errHook
+		}
+	}
+	return err
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.Addr == "" {
+		cfg.Addr = DefaultConfig.Addr
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = DefaultConfig.ReadTimeout
+	}
+	if cfg.ReadHeaderTimeout == 0 {
+		cfg.ReadHeaderTimeout = DefaultConfig.ReadHeaderTimeout
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = DefaultConfig.WriteTimeout
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = DefaultConfig.IdleTimeout
+	}
+	if cfg.MaxHeaderBytes == 0 {
+		cfg.MaxHeaderBytes = DefaultConfig.MaxHeaderBytes
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = DefaultConfig.ShutdownTimeout
+	}
+	return cfg
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "no debería dar error")
+
+	srv := New(handler, Config{ShutdownTimeout: time.Second})
+	var hooks []string
+	srv.OnDraining(func() { hooks = append(hooks, "readiness") })
+	srv.OnShutdown(func(ctx context.Context) error { hooks = append(hooks, "audit"); return nil })
+	srv.OnShutdown(func(ctx context.Context) error { hooks = append(hooks, "store"); return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+
+	status := make(chan int, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			status <- 0
+			return
+		}
+		res.Body.Close()
+		status <- res.StatusCode
+	}()
+
+	<-started
+	cancel()
+
+	assert.Equal(t, http.StatusOK, <-status, "la petición en curso debe terminar")
+	assert.Nil(t, <-done, "no debería dar error")
+	assert.Equal(t, []string{"readiness", "store", "audit"}, hooks, "los hooks de cierre corren en orden inverso, después de OnDraining")
+
+	_, err = net.Dial("tcp", ln.Addr().String())
+	assert.NotNil(t, err, "no debería aceptar conexiones nuevas")
+}
+
+func TestNew_Defaults(t *testing.T) {
+	srv := New(http.NotFoundHandler(), Config{Addr: ":9090", WriteTimeout: time.Minute})
+
+	assert.Equal(t, ":9090", srv.http.Addr, "deben ser iguales")
+	assert.Equal(t, time.Minute, srv.http.WriteTimeout, "deben ser iguales")
+	assert.Equal(t, DefaultConfig.ReadTimeout, srv.http.ReadTimeout, "deben ser iguales")
+	assert.Equal(t, DefaultConfig.MaxHeaderBytes, srv.http.MaxHeaderBytes, "deben ser iguales")
+	assert.Equal(t, DefaultConfig.ShutdownTimeout, srv.shutdownTimeout, "deben ser iguales")
+}
@@ -1,27 +1,57 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/palomavs/go-web-II/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// tracerName identifica a los spans con que FileStore.Write separa la serialización de la
+// escritura del archivo.
+const tracerName = "github.com/palomavs/go-web-II/pkg/store"
+
+// Store persiste una colección completa. Todas las operaciones abandonan el trabajo pendiente
+// y devuelven ctx.Err() cuando se cancela o vence el contexto.
 type Store interface {
-	Read(data interface{}) error
-	Write(data interface{}) error
+	Read(ctx context.Context, data interface{}) error
+	Write(ctx context.Context, data interface{}) error
+	// Lock serializa los ciclos de lectura-modificación-escritura; se libera llamando a la función devuelta.
+	Lock(ctx context.Context) (func(), error)
+	// Sequence reserva los próximos n valores de un contador persistente y devuelve el primero. El
+	// contador nunca retrocede y nunca queda por debajo de floor+1, aunque se borren los registros
+	// con los valores más altos.
+	Sequence(ctx context.Context, floor, n int) (int, error)
+	// Close espera a que terminen los ciclos bloqueados con Lock en curso y libera los recursos.
+	// Después de Close, Lock devuelve ErrClosed.
+	Close(ctx context.Context) error
 	AddMock(mock *Mock)
 	ClearMock()
 }
 
+// ErrClosed indica que se intentó usar el store después de cerrarlo.
+var ErrClosed = errors.New("store: el almacenamiento está cerrado")
+
 type Type string
 
 const (
-	FileType Type = "file"
+	FileType   Type = "file"
+	SQLiteType Type = "sqlite"
 )
 
 func New(store Type, fileName string) Store {
 	switch store {
 	case FileType:
-		return &FileStore{fileName, nil}
+		return &FileStore{FileName: fileName}
+	case SQLiteType:
+		return &SQLiteStore{FileName: fileName}
 	}
 	return nil
 }
@@ -29,12 +59,16 @@ func New(store Type, fileName string) Store {
 type FileStore struct {
 	FileName string
 	Mock     *Mock
+
+	mu     semaphore
+	closed bool
 }
 
 type Mock struct {
 	Data       []byte
 	Err        error
 	ReadCalled bool
+	Seq        int
 }
 
 func (fs *FileStore) AddMock(mock *Mock) {
@@ -45,7 +79,10 @@ func (fs *FileStore) ClearMock() {
 	fs.Mock = nil
 }
 
-func (fs *FileStore) Write(data interface{}) error {
+func (fs *FileStore) Write(ctx context.Context, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if fs.Mock != nil {
 		if fs.Mock.Err != nil {
 			return fs.Mock.Err
@@ -55,15 +92,35 @@ func (fs *FileStore) Write(data interface{}) error {
 		return nil
 	}
 
+	_, span := otel.Tracer(tracerName).Start(ctx, "FileStore.marshal")
 	fileData, err := json.MarshalIndent(data, "", " ")
+	span.SetAttributes(attribute.Int("store.bytes", len(fileData)))
+	span.End()
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(fs.FileName, fileData, 0644)
+	// Conservamos la generación anterior solo si es válida, para no pisar un backup sano con un archivo roto
+	if current, err := os.ReadFile(fs.FileName); err == nil && json.Valid(current) {
+		if err := writeFileAtomic(fs.backupName(), current, 0644); err != nil {
+			return err
+		}
+	}
+
+	// Último punto en el que se puede abandonar sin dejar una escritura a medias
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, span = otel.Tracer(tracerName).Start(ctx, "FileStore.writeFile")
+	defer span.End()
+	return writeFileAtomic(fs.FileName, fileData, 0644)
 }
 
-func (fs *FileStore) Read(data interface{}) error {
+func (fs *FileStore) Read(ctx context.Context, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if fs.Mock != nil {
 		fs.Mock.ReadCalled = true
 
@@ -74,9 +131,147 @@ func (fs *FileStore) Read(data interface{}) error {
 	}
 
 	file, err := os.ReadFile(fs.FileName)
+	if err == nil {
+		if err = json.Unmarshal(file, &data); err == nil {
+			return nil
+		}
+	}
+
+	// El archivo principal falta o está corrupto: probamos con la generación anterior
+	backup, errBackup := os.ReadFile(fs.backupName())
+	if errBackup != nil {
+		return err
+	}
+	if errBackup = json.Unmarshal(backup, &data); errBackup != nil {
+		return err
+	}
+	logging.FromContext(ctx).Warn().Err(err).Str("file", fs.FileName).Str("backup", fs.backupName()).Msg("store: no se pudo leer el archivo, se usa el backup")
+	return nil
+}
+
+func (fs *FileStore) Lock(ctx context.Context) (func(), error) {
+	if err := fs.mu.acquire(ctx); err != nil {
+		return nil, err
+	}
+	if fs.closed {
+		fs.mu.release()
+		return nil, ErrClosed
+	}
+	if fs.Mock != nil {
+		return fs.mu.release, nil
+	}
+
+	// El mutex ordena las goroutines de este proceso; el lock de archivo, a los demás procesos
+	unlockFile, err := lockFile(ctx, fs.FileName+".lock")
+	if err != nil {
+		fs.mu.release()
+		return nil, err
+	}
+	return func() {
+		unlockFile()
+		fs.mu.release()
+	}, nil
+}
+
+func (fs *FileStore) Sequence(ctx context.Context, floor, n int) (int, error) {
+	unlock, err := fs.Lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if fs.Mock != nil {
+		if fs.Mock.Err != nil {
+			return 0, fs.Mock.Err
+		}
+		fs.Mock.Seq = nextSequence(fs.Mock.Seq, floor, n)
+		return fs.Mock.Seq - n + 1, nil
+	}
+
+	var current int
+	raw, err := os.ReadFile(fs.sequenceName())
+	switch {
+	case err == nil:
+		if current, err = strconv.Atoi(strings.TrimSpace(string(raw))); err != nil {
+			return 0, err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return 0, err
+	}
+
+	last := nextSequence(current, floor, n)
+	if err := writeFileAtomic(fs.sequenceName(), []byte(strconv.Itoa(last)), 0644); err != nil {
+		return 0, err
+	}
+	return last - n + 1, nil
+}
+
+func (fs *FileStore) Close(ctx context.Context) error {
+	if err := fs.mu.acquire(ctx); err != nil {
+		return err
+	}
+	fs.closed = true
+	fs.mu.release()
+	return nil
+}
+
+// nextSequence devuelve el último de los n valores que siguen a current, sin bajar de floor.
+func nextSequence(current, floor, n int) int {
+	if current < floor {
+		current = floor
+	}
+	return current + n
+}
+
+func (fs *FileStore) backupName() string {
+	return fs.FileName + ".bak"
+}
+
+func (fs *FileStore) sequenceName() string {
+	return fs.FileName + ".seq"
+}
+
+// writeFileAtomic escribe en un temporal del mismo directorio, lo sincroniza a disco y lo renombra
+// sobre el destino, de modo que un corte a mitad de escritura nunca deja el archivo truncado.
+func writeFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(fileName)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, fileName)
+	}
 	if err != nil {
+		os.Remove(tmpName)
 		return err
 	}
 
-	return json.Unmarshal(file, &data)
+	return syncDir(dir)
+}
+
+// syncDir persiste la entrada de directorio creada por el rename.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestFileStoreWriteKeepsBackup(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "products.json")
+	fs := New(FileType, fileName)
+
+	first := []item{{Id: 1, Name: "prod1"}}
+	second := []item{{Id: 1, Name: "prod1"}, {Id: 2, Name: "prod2"}}
+	assert.Nil(t, fs.Write(context.Background(), first), "no debería dar error")
+	assert.Nil(t, fs.Write(context.Background(), second), "no debería dar error")
+
+	var result []item
+	assert.Nil(t, fs.Read(context.Background(), &result), "no debería dar error")
+	assert.Equal(t, second, result, "deben ser iguales")
+
+	backup := &FileStore{FileName: fileName + ".bak"}
+	var previous []item
+	assert.Nil(t, backup.Read(context.Background(), &previous), "no debería dar error")
+	assert.Equal(t, first, previous, "deben ser iguales")
+
+	entries, _ := os.ReadDir(filepath.Dir(fileName))
+	assert.Len(t, entries, 2, "no deben quedar temporales")
+}
+
+func TestFileStoreReadFallsBackToBackup(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "products.json")
+	fs := New(FileType, fileName)
+
+	first := []item{{Id: 1, Name: "prod1"}}
+	assert.Nil(t, fs.Write(context.Background(), first), "no debería dar error")
+	assert.Nil(t, fs.Write(context.Background(), []item{{Id: 2, Name: "prod2"}}), "no debería dar error")
+	assert.Nil(t, os.WriteFile(fileName, []byte(`[{"id": 2, "na`), 0644))
+
+	var result []item
+	assert.Nil(t, fs.Read(context.Background(), &result), "no debería dar error")
+	assert.Equal(t, first, result, "deben ser iguales")
+
+	// Escribir sobre un principal corrupto no debe pisar el backup sano
+	assert.Nil(t, fs.Write(context.Background(), []item{{Id: 3, Name: "prod3"}}), "no debería dar error")
+	backup := &FileStore{FileName: fileName + ".bak"}
+	var previous []item
+	assert.Nil(t, backup.Read(context.Background(), &previous), "no debería dar error")
+	assert.Equal(t, first, previous, "deben ser iguales")
+}
+
+func TestFileStoreReadCorruptWithoutBackup(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "products.json")
+	assert.Nil(t, os.WriteFile(fileName, []byte(`[{`), 0644))
+
+	var result []item
+	assert.NotNil(t, New(FileType, fileName).Read(context.Background(), &result), "debería dar error")
+}
+
+func TestLock_Canceled(t *testing.T) {
+	fs := New(FileType, filepath.Join(t.TempDir(), "products.json"))
+
+	unlock, err := fs.Lock(context.Background())
+	assert.Nil(t, err, "no debería dar error")
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = fs.Lock(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "la espera del lock debe respetar el contexto")
+
+	var result []item
+	assert.ErrorIs(t, fs.Read(ctx, &result), context.DeadlineExceeded, "no debería leer con el contexto vencido")
+}
+
+func TestClose_WaitsForLock(t *testing.T) {
+	fs := New(FileType, filepath.Join(t.TempDir(), "products.json"))
+
+	unlock, err := fs.Lock(context.Background())
+	assert.Nil(t, err, "no debería dar error")
+
+	closed := make(chan error, 1)
+	go func() { closed <- fs.Close(context.Background()) }()
+
+	select {
+	case <-closed:
+		t.Fatal("Close no debería terminar mientras hay una escritura en curso")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	assert.Nil(t, <-closed, "no debería dar error")
+
+	_, err = fs.Lock(context.Background())
+	assert.ErrorIs(t, err, ErrClosed, "deben ser iguales")
+}
+
+func TestSequence_ReservesRange(t *testing.T) {
+	for _, storeType := range []Type{FileType, SQLiteType} {
+		s := New(storeType, filepath.Join(t.TempDir(), "products.db"))
+
+		first, err := s.Sequence(context.Background(), 4, 3)
+		assert.Nil(t, err, "no debería dar error")
+		assert.Equal(t, 5, first, "deben ser iguales")
+
+		first, err = s.Sequence(context.Background(), 0, 1)
+		assert.Nil(t, err, "no debería dar error")
+		assert.Equal(t, 8, first, "no debe repetir valores reservados")
+		assert.Nil(t, s.Close(context.Background()), "no debería dar error")
+	}
+}
@@ -0,0 +1,48 @@
+//go:build !windows
+// +build !windows
+
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockRetry es cada cuánto se reintenta tomar un lock de archivo ocupado.
+const lockRetry = 10 * time.Millisecond
+
+// lockFile toma un lock advisory exclusivo sobre fileName, esperando hasta obtenerlo o hasta
+// que se cancele ctx.
+func lockFile(ctx context.Context, fileName string) (func(), error) {
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// flock bloqueante no se puede interrumpir, así que se reintenta sin bloquear
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
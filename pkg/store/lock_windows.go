@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package store
+
+import "context"
+
+// lockFile no tiene equivalente advisory en Windows; solo queda el mutex en proceso.
+func lockFile(ctx context.Context, fileName string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// semaphore es un mutex cuya espera se abandona si se cancela el contexto. El valor cero está
+// listo para usar.
+type semaphore struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (s *semaphore) acquire(ctx context.Context) error {
+	s.once.Do(func() { s.ch = make(chan struct{}, 1) })
+
+	select {
+	case s.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *semaphore) release() {
+	<-s.ch
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const productsTable = "products"
+
+// migrations se aplican en orden y una sola vez; el índice alcanzado se guarda en PRAGMA user_version.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS products (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		color TEXT NOT NULL,
+		price REAL NOT NULL,
+		stock INTEGER NOT NULL,
+		code TEXT NOT NULL,
+		published BOOLEAN NOT NULL,
+		creation_date TEXT NOT NULL,
+		active BOOLEAN NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS sequences (
+		name TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	)`,
+	`ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP`,
+	`ALTER TABLE products ADD COLUMN deleted_by TEXT NOT NULL DEFAULT ''`,
+	// No es UNIQUE: el alcance de la unicidad es configurable y puede haber duplicados previos
+	`CREATE INDEX IF NOT EXISTS products_code ON products (code COLLATE NOCASE)`,
+}
+
+// SequenceQuery avanza el contador name a MAX(valor actual, floor) + n en una sola sentencia
+// y devuelve el nuevo valor, el último de los n reservados. Recibe name, floor y n como parámetros.
+const SequenceQuery = `INSERT INTO sequences (name, value) VALUES (?1, ?2 + ?3)
+	ON CONFLICT (name) DO UPDATE SET value = MAX(value, ?2) + ?3
+	RETURNING value`
+
+type SQLiteStore struct {
+	FileName string
+	Mock     *Mock
+
+	mu     semaphore
+	closed bool
+	once   sync.Once
+	db     *sql.DB
+	err    error
+}
+
+func (s *SQLiteStore) AddMock(mock *Mock) {
+	s.Mock = mock
+}
+
+func (s *SQLiteStore) ClearMock() {
+	s.Mock = nil
+}
+
+// Lock solo ordena las goroutines del proceso: entre procesos ya serializa SQLite.
+func (s *SQLiteStore) Lock(ctx context.Context) (func(), error) {
+	if err := s.mu.acquire(ctx); err != nil {
+		return nil, err
+	}
+	if s.closed {
+		s.mu.release()
+		return nil, ErrClosed
+	}
+	return s.mu.release, nil
+}
+
+// Close además cierra la base, que a su vez espera las consultas en curso.
+func (s *SQLiteStore) Close(ctx context.Context) error {
+	if err := s.mu.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.mu.release()
+
+	s.closed = true
+	// Si la base todavía no se abrió, ya no se abrirá
+	s.once.Do(func() { s.err = ErrClosed })
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Sequence(ctx context.Context, floor, n int) (int, error) {
+	if s.Mock != nil {
+		if err := s.mu.acquire(ctx); err != nil {
+			return 0, err
+		}
+		defer s.mu.release()
+
+		if s.Mock.Err != nil {
+			return 0, s.Mock.Err
+		}
+		s.Mock.Seq = nextSequence(s.Mock.Seq, floor, n)
+		return s.Mock.Seq - n + 1, nil
+	}
+
+	db, err := s.DB()
+	if err != nil {
+		return 0, err
+	}
+
+	var last int
+	if err := db.QueryRowContext(ctx, SequenceQuery, productsTable, floor, n).Scan(&last); err != nil {
+		return 0, err
+	}
+	return last - n + 1, nil
+}
+
+// DB abre la base la primera vez que se necesita y aplica las migraciones pendientes.
+func (s *SQLiteStore) DB() (*sql.DB, error) {
+	s.once.Do(func() {
+		s.db, s.err = openSQLite(s.FileName)
+	})
+	return s.db, s.err
+}
+
+func openSQLite(fileName string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+fileName+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite serializa las escrituras; una sola conexión evita errores de "database is locked".
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// PRAGMA no admite parámetros, por eso el valor se interpola
+		if _, err := tx.Exec("PRAGMA user_version = " + strconv.Itoa(i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Write(ctx context.Context, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.Mock != nil {
+		if s.Mock.Err != nil {
+			return s.Mock.Err
+		}
+		products, _ := json.Marshal(data)
+		s.Mock.Data = products
+		return nil
+	}
+
+	db, err := s.DB()
+	if err != nil {
+		return err
+	}
+
+	// Pasamos por JSON para que las columnas sigan los tags de la entidad
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+productsTable); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, row := range rows {
+		columns := make([]string, 0, len(row))
+		placeholders := make([]string, 0, len(row))
+		values := make([]interface{}, 0, len(row))
+		for key, value := range row {
+			columns = append(columns, columnName(key))
+			placeholders = append(placeholders, "?")
+			values = append(values, value)
+		}
+		query := "INSERT INTO " + productsTable + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Read(ctx context.Context, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.Mock != nil {
+		s.Mock.ReadCalled = true
+
+		if s.Mock.Err != nil {
+			return s.Mock.Err
+		}
+		return json.Unmarshal(s.Mock.Data, data)
+	}
+
+	db, err := s.DB()
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+productsTable+" ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[jsonKey(column)] = values[i]
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, data)
+}
+
+// columnName convierte una clave JSON en camelCase a su columna en snake_case (creationDate -> creation_date).
+func columnName(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// jsonKey es la inversa de columnName.
+func jsonKey(column string) string {
+	parts := strings.Split(column, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
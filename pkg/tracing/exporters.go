@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func init() {
+	RegisterExporter("stdout", newStdoutExporter)
+	RegisterExporter("otlp-file", newOTLPFileExporter)
+	RegisterExporter("otlp-http", newOTLPHTTPExporter)
+}
+
+// newStdoutExporter escribe los spans en JSON legible en target, o en la salida estándar si
+// target está vacío.
+func newStdoutExporter(ctx context.Context, target string) (sdktrace.SpanExporter, error) {
+	var w io.Writer = os.Stdout
+	if target != "" {
+		f, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	return stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+}
+
+// newOTLPFileExporter escribe cada lote en target como una línea con el ExportTraceServiceRequest
+// de OTLP en JSON (protojson: los ids van en base64, no en hex).
+func newOTLPFileExporter(ctx context.Context, target string) (sdktrace.SpanExporter, error) {
+	if target == "" {
+		target = "traces.jsonl"
+	}
+	return otlptrace.New(ctx, &fileClient{fileName: target})
+}
+
+// newOTLPHTTPExporter envía los spans a un collector por OTLP/HTTP. Sin target se usan las
+// variables OTEL_EXPORTER_OTLP_* estándar.
+func newOTLPHTTPExporter(ctx context.Context, target string) (sdktrace.SpanExporter, error) {
+	var opts []otlptracehttp.Option
+	if target != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(target))
+	}
+	return otlptracehttp.New(ctx, opts...)
+}
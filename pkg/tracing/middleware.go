@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware crea un span de tipo server por petición, continuando la traza del header
+// traceparent si viene, y deja el contexto con el span en ctx.Request. Va después de
+// logging.Middleware para poder agregar el trace_id a los logs.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		c := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		route := ctx.FullPath()
+		name := ctx.Request.Method + " " + route
+		if route == "" {
+			name = ctx.Request.Method
+		}
+
+		c, span := otel.Tracer(InstrumentationName).Start(c, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(ctx.Request.Method),
+				semconv.HTTPRouteKey.String(route),
+				semconv.HTTPTargetKey.String(ctx.Request.URL.Path),
+				semconv.HTTPClientIPKey.String(ctx.ClientIP()),
+			),
+		)
+		defer span.End()
+
+		// Con el middleware de logging delante, cada línea de log de la petición lleva su traza
+		if id := logging.RequestID(c); id != "" {
+			span.SetAttributes(attribute.String("http.request_id", id))
+			logging.AddFields(c, map[string]interface{}{
+				"trace_id": span.SpanContext().TraceID().String(),
+				"span_id":  span.SpanContext().SpanID().String(),
+			})
+		}
+
+		ctx.Request = ctx.Request.WithContext(c)
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(ctx.Errors) > 0 {
+			span.RecordError(ctx.Errors.Last().Err)
+		}
+	}
+}
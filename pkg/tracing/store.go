@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/palomavs/go-web-II/pkg/store"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracedStore struct {
+	store.Store
+	name attribute.KeyValue
+}
+
+// Store devuelve un store.Store que crea un span por cada operación de s.
+func Store(name string, s store.Store) store.Store {
+	return &tracedStore{Store: s, name: attribute.String("store.name", name)}
+}
+
+func (s *tracedStore) Read(ctx context.Context, data interface{}) (err error) {
+	ctx, span := Start(ctx, "store.Read", s.name)
+	defer func() { End(span, err) }()
+
+	return s.Store.Read(ctx, data)
+}
+
+func (s *tracedStore) Write(ctx context.Context, data interface{}) (err error) {
+	ctx, span := Start(ctx, "store.Write", s.name)
+	defer func() { End(span, err) }()
+
+	return s.Store.Write(ctx, data)
+}
+
+func (s *tracedStore) Lock(ctx context.Context) (unlock func(), err error) {
+	ctx, span := Start(ctx, "store.Lock", s.name)
+	defer func() { End(span, err) }()
+
+	return s.Store.Lock(ctx)
+}
+
+func (s *tracedStore) Sequence(ctx context.Context, floor, n int) (first int, err error) {
+	ctx, span := Start(ctx, "store.Sequence", s.name)
+	defer func() { End(span, err) }()
+
+	return s.Store.Sequence(ctx, floor, n)
+}
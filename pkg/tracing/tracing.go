@@ -0,0 +1,108 @@
+// Package tracing configura OpenTelemetry: el proveedor de spans con el exporter elegido, la
+// propagación W3C (traceparent y baggage) y los spans de cada petición y de cada operación del
+// almacenamiento.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifica a los spans creados por esta API.
+const InstrumentationName = "github.com/palomavs/go-web-II"
+
+type Config struct {
+	ServiceName string
+	// Exporter es el nombre de un exporter registrado; "none" o vacío deshabilita el tracing.
+	Exporter string
+	// Target es el destino del exporter: un archivo para stdout y otlp-file, una URL para otlp-http.
+	Target string
+	// SampleRatio es la fracción de trazas nuevas que se registran, entre 0 y 1. Si la petición
+	// trae un traceparent se respeta la decisión del llamador.
+	SampleRatio float64
+}
+
+// ExporterFactory crea un exporter para target.
+type ExporterFactory func(ctx context.Context, target string) (sdktrace.SpanExporter, error)
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]ExporterFactory{}
+)
+
+// RegisterExporter agrega un exporter que luego se puede elegir por nombre en Config.Exporter.
+func RegisterExporter(name string, factory ExporterFactory) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[name] = factory
+}
+
+// Exporters devuelve los nombres de los exporters registrados.
+func Exporters() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Setup instala el proveedor global de spans y el propagador W3C. La función devuelta vacía los
+// spans pendientes y cierra el exporter; hay que llamarla al apagar el servidor.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Exporter == "" || cfg.Exporter == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exportersMu.RLock()
+	factory, ok := exporters[cfg.Exporter]
+	exportersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("exporter de tracing desconocido %q, los disponibles son: %s", cfg.Exporter, strings.Join(Exporters(), ", "))
+	}
+
+	exporter, err := factory(ctx, cfg.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(cfg.ServiceName))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start crea un span hijo del que haya en ctx usando el proveedor global.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End cierra span registrando err, si lo hay, como estado del span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
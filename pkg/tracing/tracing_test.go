@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/pkg/store"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record instala un proveedor que guarda en memoria los spans terminados.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	_, err := Setup(context.Background(), Config{Exporter: "none"})
+	assert.Nil(t, err, "no debería dar error")
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+func TestMiddleware_ContinuesTrace(t *testing.T) {
+	recorder := record(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/products/:id", func(ctx *gin.Context) {
+		_, span := Start(ctx.Request.Context(), "handler")
+		span.End()
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Equal(t, []string{"handler", "GET /products/:id"}, spanNames(spans), "deben ser iguales")
+
+	server := spans[1]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String(), "debe continuar la traza del llamador")
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String(), "deben ser iguales")
+	assert.Equal(t, server.SpanContext().SpanID(), spans[0].Parent().SpanID(), "el span del handler debe ser hijo del de la petición")
+	assert.Equal(t, codes.Error, server.Status().Code, "un 5xx debe marcar el span con error")
+}
+
+func TestStore(t *testing.T) {
+	recorder := record(t)
+	fileName := filepath.Join(t.TempDir(), "products.json")
+	s := Store("products", store.New(store.FileType, fileName))
+
+	assert.Nil(t, s.Write(context.Background(), []int{1, 2}), "no debería dar error")
+	assert.Equal(t, []string{"FileStore.marshal", "FileStore.writeFile", "store.Write"}, spanNames(recorder.Ended()), "deben ser iguales")
+
+	errTest := errors.New("disco lleno")
+	s.AddMock(&store.Mock{Err: errTest})
+	var data []int
+	assert.ErrorIs(t, s.Read(context.Background(), &data), errTest, "debería dar error")
+
+	spans := recorder.Ended()
+	read := spans[len(spans)-1]
+	assert.Equal(t, "store.Read", read.Name(), "deben ser iguales")
+	assert.Equal(t, codes.Error, read.Status().Code, "deben ser iguales")
+}
+
+func TestSetup_OTLPFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "traces.jsonl")
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "test", Exporter: "otlp-file", Target: fileName, SampleRatio: 1})
+	assert.Nil(t, err, "no debería dar error")
+
+	_, span := Start(context.Background(), "operación")
+	span.End()
+	assert.Nil(t, shutdown(context.Background()), "no debería dar error")
+
+	data, err := os.ReadFile(fileName)
+	assert.Nil(t, err, "no debería dar error")
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 1, "debe haber un lote por línea")
+	assert.Contains(t, lines[0], `"resourceSpans"`)
+	assert.Contains(t, lines[0], `"name":"operación"`)
+	assert.Contains(t, lines[0], `"stringValue":"test"`)
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.NotNil(t, err, "debería dar error")
+}
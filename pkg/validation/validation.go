@@ -0,0 +1,114 @@
+// Package validation evalúa las reglas declaradas con el tag `validate` en las entidades y
+// devuelve todas las violaciones juntas como un error de validación de apperrors.
+//
+// Además de las reglas de go-playground/validator (required, min, max, len...) se registran:
+//   - pattern=<nombre>: el valor debe cumplir la expresión registrada con RegisterPattern.
+//   - date=<layout>: el valor debe poder interpretarse como fecha con el layout de time.Parse.
+package validation
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/i18n"
+)
+
+var (
+	validate = newValidator()
+
+	patternsMu sync.RWMutex
+	patterns   = map[string]*regexp.Regexp{}
+)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Las violaciones se reportan con el nombre que ve el cliente, no el del struct
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("pattern", func(fl validator.FieldLevel) bool {
+		patternsMu.RLock()
+		re, ok := patterns[fl.Param()]
+		patternsMu.RUnlock()
+		return ok && re.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("date", func(fl validator.FieldLevel) bool {
+		_, err := time.Parse(fl.Param(), fl.Field().String())
+		return err == nil
+	})
+
+	return v
+}
+
+// RegisterPattern asocia una expresión regular a un nombre usable como pattern=<nombre>.
+// Las expresiones se registran por nombre porque el tag no admite comas ni llaves sin escapar.
+func RegisterPattern(name, expr string) {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	patterns[name] = regexp.MustCompile(expr)
+}
+
+// Struct valida todos los campos de s.
+func Struct(s interface{}) error {
+	return toAppError(validate.Struct(s))
+}
+
+// StructPartial valida solo los campos indicados (por nombre de campo del struct).
+func StructPartial(s interface{}, fields ...string) error {
+	return toAppError(validate.StructPartial(s, fields...))
+}
+
+func toAppError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	fields := make([]apperrors.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields = append(fields, fieldError(fe))
+	}
+
+	appErr := apperrors.Validation("validation_failed")
+	appErr.Fields = fields
+	// Message queda en el idioma por defecto; la capa web lo traduce según el cliente
+	appErr.Fields = appErr.LocalizeFields(i18n.Default.DefaultLocale())
+	return appErr
+}
+
+// fieldError traduce una violación de validator a un código estable para los clientes.
+// Las reglas de longitud sobre strings se distinguen de las de valor sobre números.
+func fieldError(fe validator.FieldError) apperrors.FieldError {
+	field := apperrors.FieldError{Field: fe.Field(), Code: fe.Tag(), Param: fe.Param()}
+	isString := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "min", "max":
+		if isString {
+			field.Code = fe.Tag() + "_length"
+		}
+	case "pattern":
+		field.Param = ""
+	case "date":
+		// Un ejemplo se entiende en cualquier idioma, a diferencia del layout de Go
+		field.Param = exampleDate.Format(fe.Param())
+	}
+	return field
+}
+
+var exampleDate = time.Date(2021, time.December, 13, 0, 0, 0, 0, time.UTC)
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/i18n"
+	"github.com/palomavs/go-web-II/pkg/logging"
+)
+
+var statusByKind = map[apperrors.Kind]int{
+	apperrors.KindNotFound:             http.StatusNotFound,
+	apperrors.KindValidation:           http.StatusBadRequest,
+	apperrors.KindConflict:             http.StatusConflict,
+	apperrors.KindUnavailable:          http.StatusServiceUnavailable,
+	apperrors.KindUnauthorized:         http.StatusUnauthorized,
+	apperrors.KindForbidden:            http.StatusForbidden,
+	apperrors.KindTimeout:              http.StatusGatewayTimeout,
+	apperrors.KindCanceled:             StatusClientClosedRequest,
+	apperrors.KindPreconditionFailed:   http.StatusPreconditionFailed,
+	apperrors.KindUnsupportedMediaType: http.StatusUnsupportedMediaType,
+}
+
+// StatusClientClosedRequest indica que el cliente cortó la conexión antes de recibir la respuesta.
+// No es estándar (lo introdujo nginx) pero permite distinguir estos casos en los logs.
+const StatusClientClosedRequest = 499
+
+// StatusOf devuelve el código HTTP que corresponde a err; los errores no tipados son 500.
+func StatusOf(err error) int {
+	if status, ok := statusByKind[apperrors.KindOf(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorHandler es el único lugar donde un error se convierte en respuesta: los handlers
+// lo registran con ctx.Error y este middleware escribe el código y el cuerpo, con el mensaje
+// en el idioma que pide el header Accept-Language.
+func ErrorHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+
+		err := ctx.Errors.Last().Err
+		status := StatusOf(err)
+		locale := i18n.Default.Negotiate(ctx.GetHeader("Accept-Language"))
+		message, fields := apperrors.Localize(err, locale)
+		if status >= http.StatusInternalServerError {
+			logging.FromContext(ctx.Request.Context()).Error().Err(errorCause(err)).Msg("error al atender la petición")
+		}
+		requestID := logging.RequestID(ctx.Request.Context())
+		if status == http.StatusInternalServerError {
+			message = i18n.Default.Message(locale, "internal_error")
+		}
+
+		ctx.Header("Content-Language", locale)
+		if WantsProblem(ctx.Request) {
+			ctx.Header("Content-Type", ProblemContentType)
+			problem := NewProblem(status, err, message, ctx.Request.URL.RequestURI())
+			problem.Errors = fields
+			problem.RequestID = requestID
+			ctx.AbortWithStatusJSON(status, problem)
+			return
+		}
+		response := NewResponse(status, nil, message)
+		response.Errors = fields
+		response.RequestID = requestID
+		ctx.AbortWithStatusJSON(status, response)
+	}
+}
+
+// errorCause devuelve el error original para el log, no el mensaje pensado para el cliente.
+func errorCause(err error) error {
+	if cause := errors.Unwrap(err); cause != nil {
+		return cause
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/palomavs/go-web-II/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusOf(t *testing.T) {
+	cases := map[error]int{
+		apperrors.NotFound("no encontrado"):             http.StatusNotFound,
+		apperrors.Validation("inválido"):                http.StatusBadRequest,
+		apperrors.Conflict("duplicado"):                 http.StatusConflict,
+		apperrors.Unauthorized("sin permisos"):          http.StatusUnauthorized,
+		apperrors.Unavailable(errors.New("disco")):      http.StatusServiceUnavailable,
+		apperrors.Unavailable(context.DeadlineExceeded): http.StatusGatewayTimeout,
+		apperrors.Unavailable(context.Canceled):         StatusClientClosedRequest,
+		errors.New("otro"):                              http.StatusInternalServerError,
+	}
+
+	for err, expected := range cases {
+		assert.Equal(t, expected, StatusOf(err), err.Error())
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/unavailable", func(ctx *gin.Context) {
+		ctx.Error(apperrors.Unavailable(errors.New("open products.json: no such file or directory")))
+	})
+	r.GET("/internal", func(ctx *gin.Context) {
+		ctx.Error(errors.New("detalle que no debe filtrarse"))
+	})
+
+	for path, expected := range map[string]Response{
+		"/unavailable": {Code: "503", Error: "no se pudo acceder al almacenamiento"},
+		"/internal":    {Code: "500", Error: "error interno del servidor"},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+		var res Response
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		assert.Equal(t, expected, res, path)
+	}
+}
+
+func TestErrorHandlerProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.POST("/products", func(ctx *gin.Context) {
+		err := apperrors.Validation("validation_failed")
+		err.Fields = []apperrors.FieldError{{Field: "price", Code: "min", Param: "0"}}
+		ctx.Error(err)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/products?dryRun=true", nil)
+	req.Header.Set("Accept", "application/problem+json, application/json;q=0.5")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var res Problem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.Equal(t, Problem{
+		Type:     "/problems/validation",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   "price: debe ser mayor o igual a 0",
+		Instance: "/products?dryRun=true",
+		Errors:   []apperrors.FieldError{{Field: "price", Code: "min", Message: "debe ser mayor o igual a 0"}},
+	}, res)
+}
+
+func TestErrorHandlerLocalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/products/:id", func(ctx *gin.Context) {
+		ctx.Error(apperrors.NotFound("product_not_found", 2))
+	})
+
+	for acceptLanguage, expected := range map[string]string{
+		"":                "producto de id 2 no encontrado",
+		"en-US,en;q=0.9":  "product with id 2 not found",
+		"es-AR, en;q=0.5": "producto de id 2 no encontrado",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/products/2", nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		var res Response
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		assert.Equal(t, expected, res.Error, acceptLanguage)
+	}
+}
+
+func TestWantsProblem(t *testing.T) {
+	cases := map[string]bool{
+		"":                         false,
+		"*/*":                      false,
+		"application/json":         false,
+		"application/problem+json": true,
+		"application/json, application/problem+json;q=0.9": false,
+		"application/problem+json;q=0":                     false,
+	}
+
+	for accept, expected := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		assert.Equal(t, expected, WantsProblem(req), accept)
+	}
+}
+
+func TestErrorHandlerRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger, _ := logging.New(io.Discard, "error")
+	r := gin.New()
+	r.Use(logging.Middleware(logger), ErrorHandler())
+	r.GET("/products/:id", func(ctx *gin.Context) {
+		ctx.Error(apperrors.NotFound("product_not_found", 1))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-1")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var res Response
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	assert.Equal(t, "req-1", res.RequestID, "deben ser iguales")
+
+	req.Header.Set("Accept", ProblemContentType)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var problem Problem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, "req-1", problem.RequestID, "deben ser iguales")
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem es la representación de errores de RFC 7807. Convive con Response: se elige
+// por el header Accept, así los clientes existentes siguen recibiendo el sobre de siempre.
+type Problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+	// RequestID es una extensión del problema que permite encontrar la petición en los logs.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// NewProblem arma el problema para err. El type identifica la clase de error y es estable
+// para que los clientes puedan ramificar sobre él; los errores no tipados usan about:blank.
+func NewProblem(status int, err error, detail, instance string) Problem {
+	problemType := "about:blank"
+	if kind := apperrors.KindOf(err); kind != "" {
+		problemType = "/problems/" + strings.ReplaceAll(string(kind), "_", "-")
+	}
+
+	return Problem{
+		Type:     problemType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+		Errors:   apperrors.FieldsOf(err),
+	}
+}
+
+// WantsProblem indica si el cliente prefiere application/problem+json frente a application/json.
+func WantsProblem(r *http.Request) bool {
+	problem, json := -1.0, -1.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case ProblemContentType:
+			problem = q
+		case "application/json":
+			json = q
+		}
+	}
+
+	return problem > 0 && problem >= json
+}
@@ -1,16 +1,41 @@
 package web
 
-import "strconv"
+import (
+	"strconv"
+
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+)
 
 type Response struct {
-	Code  string      `json:"code"`
-	Data  interface{} `json:"data,omitempty"`
-	Error string      `json:"error,omitempty"`
+	Code       string                 `json:"code"`
+	Data       interface{}            `json:"data,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Errors     []apperrors.FieldError `json:"errors,omitempty"`
+	Pagination *Pagination            `json:"pagination,omitempty"`
+	// RequestID acompaña a los errores para poder encontrar la petición en los logs.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// Pagination acompaña a las respuestas de listados paginados. Next y Prev son enlaces
+// relativos listos para usar; se omiten cuando no hay página siguiente o anterior.
+type Pagination struct {
+	Total  int    `json:"total"`
+	Count  int    `json:"count"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit,omitempty"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
 }
 
 func NewResponse(code int, data interface{}, err string) Response {
 	if code < 300 {
-		return Response{strconv.FormatInt(int64(code), 10), data, ""}
+		return Response{Code: strconv.FormatInt(int64(code), 10), Data: data}
 	}
-	return Response{strconv.FormatInt(int64(code), 10), nil, err}
+	return Response{Code: strconv.FormatInt(int64(code), 10), Error: err}
+}
+
+func NewPaginatedResponse(code int, data interface{}, pagination Pagination) Response {
+	response := NewResponse(code, data, "")
+	response.Pagination = &pagination
+	return response
 }
@@ -0,0 +1,26 @@
+package web
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout limita a d el tiempo que el resto de la cadena puede trabajar en cada petición: el
+// contexto de la petición vence a los d y el almacenamiento abandona lo que tenga pendiente.
+// Con d <= 0 no se impone límite y solo cuenta la desconexión del cliente.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if d <= 0 {
+			ctx.Next()
+			return
+		}
+
+		c, cancel := context.WithTimeout(ctx.Request.Context(), d)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(c)
+		ctx.Next()
+	}
+}
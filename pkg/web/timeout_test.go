@@ -0,0 +1,30 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/palomavs/go-web-II/pkg/apperrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler(), Timeout(20*time.Millisecond))
+	r.GET("/lento", func(ctx *gin.Context) {
+		select {
+		case <-ctx.Request.Context().Done():
+			ctx.Error(apperrors.Unavailable(ctx.Request.Context().Err()))
+		case <-time.After(time.Second):
+			ctx.Status(http.StatusOK)
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/lento", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code, "deben ser iguales")
+}